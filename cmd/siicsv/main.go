@@ -0,0 +1,77 @@
+// Command siicsv writes the units of one type found in a save as CSV, one row per unit.
+//
+// Usage:
+//
+//	siicsv -type vehicle -out vehicles.csv game.sii
+//	siicsv -type job_offer_data -tsv -explode game.sii > offers.tsv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/CaptainFallaway/SiiUnitParser/pkg/siiunit"
+)
+
+func main() {
+	utype := flag.String("type", "", "unit type to export")
+	tsv := flag.Bool("tsv", false, "write tab separated values instead of CSV")
+	explode := flag.Bool("explode", false, "write one row per array element instead of joining the elements")
+	sep := flag.String("sep", ";", "separator of joined array elements, elements holding it are double quoted")
+	out := flag.String("out", "", "output file, stdout if empty")
+	flag.Parse()
+
+	if err := run(*utype, *tsv, *explode, *sep, *out, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "siicsv:", err)
+		os.Exit(1)
+	}
+}
+
+func run(utype string, tsv, explode bool, sep, out string, args []string) error {
+	if utype == "" {
+		return fmt.Errorf("no -type given")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("need exactly 1 save, got %d", len(args))
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Only the units of the exported type are decoded
+	units, err := siiunit.ParseAllUnitsConcurrent(file, siiunit.OptUnitTypes(utype))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[0], err)
+	}
+	if len(units) == 0 {
+		fmt.Fprintf(os.Stderr, "siicsv: no units of type %s in %s\n", utype, args[0])
+	}
+
+	opts := []siiunit.CSVOption{siiunit.OptCSVArraySep(sep)}
+	if tsv {
+		opts = append(opts, siiunit.OptCSVComma('\t'))
+	}
+	if explode {
+		opts = append(opts, siiunit.OptCSVExplode())
+	}
+
+	if out == "" {
+		return siiunit.WriteUnitsCSV(os.Stdout, units, utype, opts...)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := siiunit.WriteUnitsCSV(f, units, utype, opts...); err != nil {
+		f.Close()
+		return err
+	}
+
+	// A failed close can lose the end of the file
+	return f.Close()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/CaptainFallaway/SiiUnitParser/pkg/siiunit"
+)
+
+// goTypes maps attribute type names to the Go type Unmarshal and Marshal use for them
+var goTypes = map[string]string{
+	"string":    "string",
+	"float":     "float64",
+	"float2":    "[2]float64",
+	"float3":    "[3]float64",
+	"float4":    "[4]float64",
+	"placement": "siiunit.Placement",
+	"int":       "int64",
+	"int2":      "[2]int64",
+	"int3":      "[3]int64",
+	"int4":      "[4]int64",
+	"bool":      "bool",
+	"any":       "siiunit.Attribute",
+}
+
+// generate writes a Go source file with one struct and constructor per unit type in the schema
+func generate(schema *siiunit.Schema, pkg, source string) ([]byte, error) {
+	var b bytes.Buffer
+
+	for _, us := range schema.Units {
+		typeName := goName(us.Utype)
+
+		fmt.Fprintf(&b, "// %s is a %s unit, inferred from %d samples\n", typeName, us.Utype, us.Samples)
+		fmt.Fprintf(&b, "type %s struct {\n", typeName)
+		b.WriteString("\tID string `sii:\",id\"`\n")
+		fmt.Fprintf(&b, "\tUtype string `sii:\"%s,utype\"`\n", us.Utype)
+
+		used := map[string]bool{"ID": true, "Utype": true}
+		for _, f := range us.Fields {
+			name := goName(f.Key)
+			for i := 2; used[name]; i++ {
+				name = fmt.Sprintf("%s%d", goName(f.Key), i)
+			}
+			used[name] = true
+
+			if f.Optional(us) {
+				fmt.Fprintf(&b, "\n\t// Optional, seen in %d of %d samples\n", f.Seen, us.Samples)
+			}
+			fmt.Fprintf(&b, "\t%s %s `sii:\"%s\"`\n", name, fieldType(f), fieldTag(f))
+		}
+
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "// New%s creates a %s unit with the given ID\n", typeName, us.Utype)
+		fmt.Fprintf(&b, "func New%s(id string) *%s {\n", typeName, typeName)
+		fmt.Fprintf(&b, "\treturn &%s{ID: id, Utype: %q}\n", typeName, us.Utype)
+		b.WriteString("}\n\n")
+	}
+
+	var header bytes.Buffer
+
+	fmt.Fprintf(&header, "// Code generated by siigen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&header, "package %s\n\n", pkg)
+	if bytes.Contains(b.Bytes(), []byte("siiunit.")) {
+		header.WriteString("import \"github.com/CaptainFallaway/SiiUnitParser/pkg/siiunit\"\n\n")
+	}
+
+	src, err := format.Source(append(header.Bytes(), b.Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+
+	return src, nil
+}
+
+func fieldType(f *siiunit.FieldSchema) string {
+	// Only ever seen as "key: 0", which is an int as far as Unmarshal is concerned
+	if f.Empty {
+		return "int64"
+	}
+
+	t := goTypes[f.Type]
+	if f.Array {
+		return "[]" + t
+	}
+	return t
+}
+
+func fieldTag(f *siiunit.FieldSchema) string {
+	opts := []string{f.Key}
+
+	if f.Type == "string" && f.Token {
+		opts = append(opts, "token")
+	}
+	if f.Hex {
+		opts = append(opts, "hexfloat")
+	}
+	if f.Mixed {
+		opts = append(opts, "lenient")
+	}
+
+	return strings.Join(opts, ",")
+}
+
+// goName turns a snake_case SII name into an exported Go identifier
+func goName(s string) string {
+	var b strings.Builder
+
+	for part := range strings.FieldsFuncSeq(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if part == "id" {
+			b.WriteString("ID")
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "X" + name
+	}
+	return name
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/CaptainFallaway/SiiUnitParser/pkg/siiunit"
+)
+
+const testSave = `SiiNunit
+{
+vehicle : _nameless.1d4.8d36.7060 {
+ odometer: 512345
+ fuel_relative: &3f000000
+ wear: (0, 0)
+ accessories: 1
+ accessories[0]: _nameless.1d4.8d36.7080
+ license_plate: "<offset hshift=-10>AB 123"
+}
+vehicle : _nameless.1d4.8d36.7070 {
+ odometer: 10
+ fuel_relative: &3f800000
+ wear: (0.5, 0.25)
+ accessories: 0
+ trailer_connected: true
+}
+}
+`
+
+// TestGenerate tests the generated code for a small set of samples
+func TestGenerate(t *testing.T) {
+	units, err := siiunit.ParseAllUnits(strings.NewReader(testSave))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	src, err := generate(siiunit.InferSchema(units), "saves", "test.sii")
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "units_gen.go", src, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+
+	want := []string{
+		"type Vehicle struct {",
+		"Odometer int64 `sii:\"odometer\"`",
+		"FuelRelative float64 `sii:\"fuel_relative,hexfloat\"`",
+		"Wear [2]float64 `sii:\"wear,lenient\"`",
+		"Accessories []string `sii:\"accessories,token\"`",
+		"// Optional, seen in 1 of 2 samples",
+		"TrailerConnected bool `sii:\"trailer_connected\"`",
+		"func NewVehicle(id string) *Vehicle {",
+	}
+	// Ignore the gofmt alignment
+	flat := strings.Join(strings.Fields(string(src)), " ")
+	for _, w := range want {
+		if !strings.Contains(flat, w) {
+			t.Errorf("generated code is missing %q\n%s", w, src)
+		}
+	}
+}
+
+// TestGoName tests converting SII names to Go identifiers
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"job_offer_data": "JobOfferData",
+		"player_id":      "PlayerID",
+		"2nd_trailer":    "X2ndTrailer",
+		"company":        "Company",
+	}
+
+	for in, want := range tests {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Command siigen generates Go structs with sii tags for unit types found in
+// sample saves or in a schema file written by siigen -schema-out.
+//
+// Usage with go generate:
+//
+//	//go:generate go run github.com/CaptainFallaway/SiiUnitParser/cmd/siigen -types company,vehicle -pkg saves -out units_gen.go game.sii
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/CaptainFallaway/SiiUnitParser/pkg/siiunit"
+)
+
+func main() {
+	schemaIn := flag.String("schema", "", "read the schema from this JSON file instead of sample saves")
+	schemaOut := flag.String("schema-out", "", "also write the inferred schema to this JSON file")
+	types := flag.String("types", "", "comma separated unit types to generate, all if empty")
+	pkg := flag.String("pkg", "main", "package name of the generated file")
+	out := flag.String("out", "", "output file, stdout if empty")
+	flag.Parse()
+
+	if err := run(*schemaIn, *schemaOut, *types, *pkg, *out, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "siigen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(schemaIn, schemaOut, types, pkg, out string, saves []string) error {
+	var opts []siiunit.ParserOption
+	if types != "" {
+		opts = append(opts, siiunit.OptUnitTypes(strings.Split(types, ",")...))
+	}
+
+	schema, source, err := loadSchema(schemaIn, saves, opts)
+	if err != nil {
+		return err
+	}
+
+	if types != "" {
+		wanted := strings.Split(types, ",")
+		schema.Units = slices.DeleteFunc(schema.Units, func(us *siiunit.UnitSchema) bool {
+			return !slices.Contains(wanted, us.Utype)
+		})
+	}
+
+	if schemaOut != "" {
+		file, err := os.Create(schemaOut)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := schema.WriteJSON(file); err != nil {
+			return err
+		}
+	}
+
+	src, err := generate(schema, pkg, source)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+
+	return os.WriteFile(out, src, 0644)
+}
+
+// loadSchema reads the schema file, or infers a schema from every sample save
+func loadSchema(schemaIn string, saves []string, opts []siiunit.ParserOption) (*siiunit.Schema, string, error) {
+	if schemaIn != "" {
+		file, err := os.Open(schemaIn)
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+
+		schema, err := siiunit.ReadSchema(file)
+		return schema, schemaIn, err
+	}
+
+	if len(saves) == 0 {
+		return nil, "", fmt.Errorf("no sample saves or -schema given")
+	}
+
+	schema := &siiunit.Schema{}
+	symbols := siiunit.NewSymbolTable()
+
+	for _, path := range saves {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		units, err := siiunit.ParseAllUnitsConcurrent(file, append(opts, siiunit.OptSymbolTable(symbols))...)
+		file.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		schema.Add(units)
+	}
+
+	return schema, strings.Join(saves, ", "), nil
+}
@@ -0,0 +1,6 @@
+// Package sqlitetest runs the SQLite export against a real SQLite database. It is a
+// module of its own so the main module doesn't depend on a SQLite driver, run it with
+//
+//	cd internal/sqlitetest
+//	go test ./...
+package sqlitetest
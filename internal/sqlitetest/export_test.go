@@ -0,0 +1,202 @@
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CaptainFallaway/SiiUnitParser/pkg/siiunit"
+	_ "modernc.org/sqlite"
+)
+
+const testSave = `SiiNunit
+{
+economy : _nameless.1d4.8d36.7030 {
+ bank: _nameless.1d4.8d36.7040
+ player: _nameless.1d4.8d36.7050
+ companies: 1
+ companies[0]: company.volatile.scania_fac.paris
+ game_time: 24771
+}
+bank : _nameless.1d4.8d36.7040 {
+ money_account: 1523644
+ coinsurance_fixed: &3f800000
+ id: 7
+}
+player : _nameless.1d4.8d36.7050 {
+ trailer_placement: (-35478.76, 29.54, 7826.03) (0.9998; 0, -0.0179, 0)
+ assigned_truck: _nameless.1d4.8d36.7060
+}
+company : company.volatile.scania_fac.paris {
+ permanent_data: company.permanent.scania_fac
+ job_offer: 0
+}
+vehicle : _nameless.1d4.8d36.7060 {
+ accessories: 2
+ accessories[0]: _nameless.1d4.8d36.7080
+ accessories[1]: _nameless.1d4.8d36.7090
+}
+}
+`
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "saves.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func parseSave(t *testing.T) []siiunit.Unit {
+	t.Helper()
+
+	units, err := siiunit.ParseAllUnits(strings.NewReader(testSave))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+	return units
+}
+
+// columns returns the column names of a table in order
+func columns(t *testing.T, db *sql.DB, table string) string {
+	t.Helper()
+
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		t.Fatalf("pragma_table_info(%s) error = %v", table, err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+// TestExportSQLite tests a save lands in SQLite tables that can be joined back together
+func TestExportSQLite(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	saveID, err := siiunit.ExportSQLite(ctx, db, parseSave(t), siiunit.OptSQLSave("autosave", at))
+	if err != nil {
+		t.Fatalf("ExportSQLite() error = %v", err)
+	}
+	if saveID != 1 {
+		t.Errorf("ExportSQLite() = %d, want 1", saveID)
+	}
+
+	wantColumns := map[string]string{
+		"sii_saves":            "id name exported_at",
+		"sii_units":            "save_id id utype",
+		"bank":                 "save_id id money_account coinsurance_fixed attr_id",
+		"player":               "save_id id trailer_placement_x trailer_placement_y trailer_placement_z trailer_placement_rw trailer_placement_rx trailer_placement_ry trailer_placement_rz assigned_truck",
+		"vehicle__accessories": "save_id unit_id idx value",
+	}
+	for table, want := range wantColumns {
+		if got := columns(t, db, table); got != want {
+			t.Errorf("table %s columns = %s, want %s", table, got, want)
+		}
+	}
+
+	var name, exportedAt string
+	if err := db.QueryRow(`SELECT name, exported_at FROM sii_saves WHERE id = ?`, saveID).Scan(&name, &exportedAt); err != nil {
+		t.Fatalf("sii_saves query error = %v", err)
+	}
+	if name != "autosave" || exportedAt != "2024-05-01T12:00:00Z" {
+		t.Errorf("sii_saves row = %s %s", name, exportedAt)
+	}
+
+	var id string
+	var money, attrID int64
+	var coinsurance float64
+	if err := db.QueryRow(`SELECT id, money_account, coinsurance_fixed, attr_id FROM bank`).Scan(&id, &money, &coinsurance, &attrID); err != nil {
+		t.Fatalf("bank query error = %v", err)
+	}
+	if id != "_nameless.1d4.8d36.7040" || money != 1523644 || coinsurance != 1 || attrID != 7 {
+		t.Errorf("bank row = %s %d %g %d", id, money, coinsurance, attrID)
+	}
+
+	// Pointers join on sii_units
+	var utype string
+	if err := db.QueryRow(`SELECT u.utype FROM player p JOIN sii_units u ON u.save_id = p.save_id AND u.id = p.assigned_truck`).Scan(&utype); err != nil {
+		t.Fatalf("pointer join error = %v", err)
+	}
+	if utype != "vehicle" {
+		t.Errorf("assigned_truck joins to %s, want vehicle", utype)
+	}
+
+	var accessory string
+	if err := db.QueryRow(`SELECT value FROM vehicle__accessories WHERE unit_id = ? AND idx = 1`, "_nameless.1d4.8d36.7060").Scan(&accessory); err != nil {
+		t.Fatalf("vehicle__accessories query error = %v", err)
+	}
+	if accessory != "_nameless.1d4.8d36.7090" {
+		t.Errorf("accessories[1] = %s", accessory)
+	}
+
+	// The empty job_offer array has no rows and no table
+	var tables int
+	db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'company__job_offer'`).Scan(&tables)
+	if tables != 0 {
+		t.Errorf("company__job_offer created for an empty array")
+	}
+}
+
+// TestExportSQLiteAppend tests appending saves adds the columns later saves need
+func TestExportSQLiteAppend(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	units := parseSave(t)
+	if _, err := siiunit.ExportSQLite(ctx, db, units); err != nil {
+		t.Fatalf("ExportSQLite() error = %v", err)
+	}
+	if _, err := siiunit.ExportSQLite(ctx, db, units); !errors.Is(err, siiunit.ErrSQLNotEmpty) {
+		t.Errorf("ExportSQLite() without append error = %v, want %v", err, siiunit.ErrSQLNotEmpty)
+	}
+
+	units[1].Attrs.Set("loan", siiunit.NewIntAttribute(1000))
+	saveID, err := siiunit.ExportSQLite(ctx, db, units, siiunit.OptSQLAppend(), siiunit.OptSQLSave("later", time.Now()))
+	if err != nil {
+		t.Fatalf("ExportSQLite() append error = %v", err)
+	}
+	if saveID != 2 {
+		t.Errorf("ExportSQLite() append = %d, want 2", saveID)
+	}
+
+	if got, want := columns(t, db, "bank"), "save_id id money_account coinsurance_fixed attr_id loan"; got != want {
+		t.Errorf("bank columns = %s, want %s", got, want)
+	}
+
+	rows, err := db.Query(`SELECT save_id, loan FROM bank ORDER BY save_id`)
+	if err != nil {
+		t.Fatalf("bank query error = %v", err)
+	}
+	defer rows.Close()
+
+	var loans []sql.NullInt64
+	for rows.Next() {
+		var id int64
+		var loan sql.NullInt64
+		if err := rows.Scan(&id, &loan); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		loans = append(loans, loan)
+	}
+	if len(loans) != 2 || loans[0].Valid || loans[1].Int64 != 1000 {
+		t.Errorf("bank loans = %v", loans)
+	}
+}
@@ -28,25 +28,16 @@ func main() {
 		fmt.Println(unit)
 	}
 
-	unit := units[0]
-
-	unlockedDealersAttr, _ := unit.Attrs.Get("unlocked_dealers")
+	var economy struct {
+		UnlockedDealers []string `sii:"unlocked_dealers"`
+	}
 
-	unlockedDealersAttrs, err := unlockedDealersAttr.Arr()
+	err = siiunit.Unmarshal(units[0], &economy)
 	if err != nil {
 		panic(err)
 	}
 
-	var unlockedDealers []string
-	for _, attr := range unlockedDealersAttrs {
-		strVal, err := attr.String()
-		if err != nil {
-			panic(err)
-		}
-		unlockedDealers = append(unlockedDealers, strVal)
-	}
-
-	fmt.Println(unlockedDealers)
+	fmt.Println(economy.UnlockedDealers)
 
 	fmt.Println("Parsed", len(units), "units")
 	fmt.Println("Parsing took", elapsed)
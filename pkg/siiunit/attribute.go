@@ -3,6 +3,7 @@ package siiunit
 import (
 	"errors"
 	"fmt"
+	"slices"
 )
 
 // AttributeType represents the type of a SII unit attribute
@@ -38,15 +39,30 @@ var attributeTypeNames = map[AttributeType]string{
 	AttributeTypeArray:     "array",
 }
 
+// attributeTypeByName returns the attribute type with the given name
+func attributeTypeByName(name string) (AttributeType, bool) {
+	for atype, n := range attributeTypeNames {
+		if n == name {
+			return atype, true
+		}
+	}
+	return 0, false
+}
+
 // Attribute represents a SII unit attribute with its type and values
 type Attribute struct {
 	Atype AttributeType
 
 	// For scalar and basic types
 	stringVal string
+	quoted    bool // String was written in quotes rather than as a token
 	floatVal  float64
-	intVal    int64
-	boolVal   bool
+	hexFloat  bool // Floats were written in the &xxxxxxxx IEEE754 form
+	// decimals marks the floats of a hexFloat attribute that were written in decimal
+	// all the same, bit i for float i. Placements count the position then the rotation.
+	decimals uint8
+	intVal   int64
+	boolVal  bool
 
 	// For vector types
 	float2Vals [2]float64
@@ -73,15 +89,12 @@ var (
 
 // newAttribute creates a new Attribute by parsing the string value
 func newAttribute(value string) (*Attribute, error) {
-	atype := detectAttributeType(value)
-	attr := &Attribute{Atype: atype}
-
-	err := attr.parseValue(value)
+	attr, err := parseAttribute(value)
 	if err != nil {
 		return nil, err
 	}
 
-	return attr, nil
+	return &attr, nil
 }
 
 // makeArray marks this attribute as an array and initializes the array slice
@@ -101,22 +114,143 @@ func (a *Attribute) appendToArray(value string) error {
 	}
 
 	// Parse the string into a new attribute
-	attr, err := newAttribute(value)
+	attr, err := parseAttribute(value)
 	if err != nil {
 		return fmt.Errorf("failed to parse array element: %w", err)
 	}
 
+	if err := a.checkArrayElem(&attr); err != nil {
+		return err
+	}
+
+	a.arrayVals = append(a.arrayVals, attr)
+	return nil
+}
+
+// checkArrayElem makes sure elem can be stored in the array, the first element
+// sets the element type of the array. Whole floats read as ints, so ints and int
+// tuples mixed with floats of the same shape are widened to floats, in elem or in
+// the elements already stored.
+func (a *Attribute) checkArrayElem(elem *Attribute) error {
+	if elem.Atype == AttributeTypeArray {
+		return errors.New("arrays cannot be nested")
+	}
+
 	// Set element type on first append
 	if len(a.arrayVals) == 0 {
-		a.arrayElemType = attr.Atype
-	} else if attr.Atype != a.arrayElemType {
-		return fmt.Errorf("cannot append %s to array of %s", attributeTypeNames[attr.Atype], attributeTypeNames[a.arrayElemType])
+		a.arrayElemType = elem.Atype
+		return nil
+	}
+
+	switch {
+	case elem.Atype == a.arrayElemType:
+	case floatType(elem.Atype) == a.arrayElemType:
+		elem.widenToFloat()
+	case floatType(a.arrayElemType) == elem.Atype:
+		for i := range a.arrayVals {
+			a.arrayVals[i].widenToFloat()
+		}
+		a.arrayElemType = elem.Atype
+	default:
+		return fmt.Errorf("cannot append %s to array of %s", attributeTypeNames[elem.Atype], attributeTypeNames[a.arrayElemType])
 	}
 
-	a.arrayVals = append(a.arrayVals, *attr)
 	return nil
 }
 
+// floatType returns the float type of the same shape as an int type, or -1
+func floatType(atype AttributeType) AttributeType {
+	switch atype {
+	case AttributeTypeInt:
+		return AttributeTypeFloat
+	case AttributeTypeInt2:
+		return AttributeTypeFloat2
+	case AttributeTypeInt3:
+		return AttributeTypeFloat3
+	case AttributeTypeInt4:
+		return AttributeTypeFloat4
+	default:
+		return -1
+	}
+}
+
+// widenToFloat turns an int or int tuple attribute into the float type of the same shape
+func (a *Attribute) widenToFloat() {
+	switch a.Atype {
+	case AttributeTypeInt:
+		a.floatVal = float64(a.intVal)
+	case AttributeTypeInt2:
+		for i, n := range a.int2Vals {
+			a.float2Vals[i] = float64(n)
+		}
+	case AttributeTypeInt3:
+		for i, n := range a.int3Vals {
+			a.float3Vals[i] = float64(n)
+		}
+	case AttributeTypeInt4:
+		for i, n := range a.int4Vals {
+			a.float4Vals[i] = float64(n)
+		}
+	default:
+		return
+	}
+	a.Atype = floatType(a.Atype)
+}
+
+// InsertAt inserts elem into the array before index, index may be the array length to append
+func (a *Attribute) InsertAt(index int, elem Attribute) error {
+	if a.Atype != AttributeTypeArray {
+		return ErrNotAnArray
+	}
+	if index < 0 || index > len(a.arrayVals) {
+		return fmt.Errorf("%w: %d", ErrIndexOutOfRange, index)
+	}
+
+	if err := a.checkArrayElem(&elem); err != nil {
+		return err
+	}
+
+	a.arrayVals = slices.Insert(a.arrayVals, index, elem)
+	return nil
+}
+
+// RemoveAt removes the element at index from the array
+func (a *Attribute) RemoveAt(index int) error {
+	if a.Atype != AttributeTypeArray {
+		return ErrNotAnArray
+	}
+	if index < 0 || index >= len(a.arrayVals) {
+		return fmt.Errorf("%w: %d", ErrIndexOutOfRange, index)
+	}
+
+	a.arrayVals = slices.Delete(a.arrayVals, index, index+1)
+	return nil
+}
+
+// validate checks the array invariants of the attribute
+func (a *Attribute) validate() error {
+	if a.Atype != AttributeTypeArray {
+		return nil
+	}
+
+	for _, elem := range a.arrayVals {
+		if elem.Atype == AttributeTypeArray {
+			return errors.New("arrays cannot be nested")
+		}
+		if elem.Atype != a.arrayElemType {
+			return fmt.Errorf("cannot store %s in array of %s", attributeTypeNames[elem.Atype], attributeTypeNames[a.arrayElemType])
+		}
+	}
+
+	return nil
+}
+
+// clone returns a copy that doesn't share the array elements
+func (a Attribute) clone() Attribute {
+	a.arrayVals = slices.Clone(a.arrayVals)
+	return a
+}
+
 // Arr returns the array of attributes
 func (a *Attribute) Arr() ([]Attribute, error) {
 	if a.Atype != AttributeTypeArray {
@@ -0,0 +1,85 @@
+package siiunit
+
+// NewStringAttribute creates a quoted string attribute
+func NewStringAttribute(s string) Attribute {
+	return Attribute{Atype: AttributeTypeString, stringVal: s, quoted: true}
+}
+
+// NewTokenAttribute creates an unquoted string attribute, used for tokens and unit pointers
+func NewTokenAttribute(s string) Attribute {
+	return Attribute{Atype: AttributeTypeString, stringVal: s}
+}
+
+// NewNullAttribute creates a null pointer attribute
+func NewNullAttribute() Attribute {
+	return NewTokenAttribute("null")
+}
+
+// NewFloatAttribute creates a float attribute
+func NewFloatAttribute(f float64) Attribute {
+	return Attribute{Atype: AttributeTypeFloat, floatVal: f}
+}
+
+// NewHexFloatAttribute creates a float attribute that is written in &xxxxxxxx form
+func NewHexFloatAttribute(f float64) Attribute {
+	return Attribute{Atype: AttributeTypeFloat, floatVal: f, hexFloat: true}
+}
+
+// NewFloat2Attribute creates a float2 attribute
+func NewFloat2Attribute(v [2]float64) Attribute {
+	return Attribute{Atype: AttributeTypeFloat2, float2Vals: v}
+}
+
+// NewFloat3Attribute creates a float3 attribute
+func NewFloat3Attribute(v [3]float64) Attribute {
+	return Attribute{Atype: AttributeTypeFloat3, float3Vals: v}
+}
+
+// NewFloat4Attribute creates a float4 attribute
+func NewFloat4Attribute(v [4]float64) Attribute {
+	return Attribute{Atype: AttributeTypeFloat4, float4Vals: v}
+}
+
+// NewPlacementAttribute creates a placement attribute from a position and a (w, x, y, z) rotation
+func NewPlacementAttribute(pos [3]float64, rot [4]float64) Attribute {
+	return Attribute{Atype: AttributeTypePlacement, placementPos: pos, placementRot: rot}
+}
+
+// NewIntAttribute creates an int attribute
+func NewIntAttribute(i int64) Attribute {
+	return Attribute{Atype: AttributeTypeInt, intVal: i}
+}
+
+// NewInt2Attribute creates an int2 attribute
+func NewInt2Attribute(v [2]int64) Attribute {
+	return Attribute{Atype: AttributeTypeInt2, int2Vals: v}
+}
+
+// NewInt3Attribute creates an int3 attribute
+func NewInt3Attribute(v [3]int64) Attribute {
+	return Attribute{Atype: AttributeTypeInt3, int3Vals: v}
+}
+
+// NewInt4Attribute creates an int4 attribute
+func NewInt4Attribute(v [4]int64) Attribute {
+	return Attribute{Atype: AttributeTypeInt4, int4Vals: v}
+}
+
+// NewBoolAttribute creates a bool attribute
+func NewBoolAttribute(b bool) Attribute {
+	return Attribute{Atype: AttributeTypeBool, boolVal: b}
+}
+
+// NewArrayAttribute creates an array attribute, all elements must have the same type
+func NewArrayAttribute(elems ...Attribute) (Attribute, error) {
+	attr := Attribute{}
+	attr.makeArray(len(elems))
+
+	for _, elem := range elems {
+		if err := attr.InsertAt(len(attr.arrayVals), elem); err != nil {
+			return Attribute{}, err
+		}
+	}
+
+	return attr, nil
+}
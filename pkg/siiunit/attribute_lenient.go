@@ -0,0 +1,78 @@
+package siiunit
+
+// Lenient accessors coerce between attribute types that only differ because of how
+// the type was detected, e.g. a float3 whose values happened to be whole numbers is
+// detected as int3. Hex floats are floats already, so they need no coercion.
+
+// AsFloat is Float but also accepts int attributes
+func (a *Attribute) AsFloat() (float64, error) {
+	c, err := a.coerce(AttributeTypeFloat)
+	if err != nil {
+		return 0, err
+	}
+	return c.floatVal, nil
+}
+
+// AsFloat2 is Float2 but also accepts int2 attributes
+func (a *Attribute) AsFloat2() ([2]float64, error) {
+	c, err := a.coerce(AttributeTypeFloat2)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	return c.float2Vals, nil
+}
+
+// AsFloat3 is Float3 but also accepts int3 attributes
+func (a *Attribute) AsFloat3() ([3]float64, error) {
+	c, err := a.coerce(AttributeTypeFloat3)
+	if err != nil {
+		return [3]float64{}, err
+	}
+	return c.float3Vals, nil
+}
+
+// AsFloat4 is Float4 but also accepts int4 attributes
+func (a *Attribute) AsFloat4() ([4]float64, error) {
+	c, err := a.coerce(AttributeTypeFloat4)
+	if err != nil {
+		return [4]float64{}, err
+	}
+	return c.float4Vals, nil
+}
+
+// AsBool is Bool but also accepts the ints 0 and 1
+func (a *Attribute) AsBool() (bool, error) {
+	c, err := a.coerce(AttributeTypeBool)
+	if err != nil {
+		return false, err
+	}
+	return c.boolVal, nil
+}
+
+// coerce returns a copy of the attribute converted to target, if the conversion is lenient-safe
+func (a *Attribute) coerce(target AttributeType) (*Attribute, error) {
+	if a.Atype == target {
+		return a, nil
+	}
+
+	c := *a
+
+	switch {
+	case target == AttributeTypeBool && a.Atype == AttributeTypeInt:
+		if a.intVal != 0 && a.intVal != 1 {
+			return nil, ErrInvalidType
+		}
+		c = Attribute{Atype: AttributeTypeBool, boolVal: a.intVal == 1}
+
+	case target == AttributeTypeFloat || target == AttributeTypeFloat2 ||
+		target == AttributeTypeFloat3 || target == AttributeTypeFloat4:
+		if !convertAttribute(&c, attributeTypeNames[target]) {
+			return nil, ErrInvalidType
+		}
+
+	default:
+		return nil, ErrInvalidType
+	}
+
+	return &c, nil
+}
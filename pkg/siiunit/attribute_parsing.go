@@ -3,181 +3,292 @@ package siiunit
 import (
 	"fmt"
 	"math"
-	"regexp"
 	"strconv"
 	"strings"
 )
 
-func extractTupleValues(s string) []string {
-	re := regexp.MustCompile(`\(([^)]+)\)`)
-	matches := re.FindStringSubmatch(s)
-	if len(matches) < 2 {
-		return []string{}
+// parseAttribute classifies and parses a raw SII value in a single pass.
+//
+// The value is scanned once from left to right, the first byte decides which
+// shape is tried (string, tuple, hex float, number) and anything that does not
+// fit a known shape falls back to a string (tokens, owner_ptr, link_ptr,
+// resource_tie). The function does not allocate for any attribute type.
+func parseAttribute(value string) (Attribute, error) {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return Attribute{Atype: AttributeTypeString}, nil
 	}
 
-	parts := strings.Split(matches[1], ",")
-	var result []string
-	for _, p := range parts {
-		trimmed := strings.TrimSpace(p)
-		if trimmed != "" && trimmed != ";" {
-			result = append(result, trimmed)
+	switch value[0] {
+	case '"':
+		// String type (quoted)
+		if len(value) >= 2 && value[len(value)-1] == '"' {
+			return Attribute{Atype: AttributeTypeString, stringVal: value[1 : len(value)-1], quoted: true}, nil
 		}
-	}
 
-	return result
-}
+	case '(':
+		// Tuples: (x, y), (x, y, z), (x, y, z, w) and placement (x, y, z) (w; x, y, z)
+		if attr, ok := scanTuple(value); ok {
+			return attr, nil
+		}
 
-// parseValue parses the string value according to the attribute type
-func (a *Attribute) parseValue(value string) error {
-	value = strings.TrimSpace(value)
+	case '&':
+		// IEEE754 hex float
+		f, err := parseHexFloat(value)
+		if err != nil {
+			return Attribute{}, err
+		}
+		return Attribute{Atype: AttributeTypeFloat, floatVal: f, hexFloat: true}, nil
 
-	switch a.Atype {
-	case AttributeTypeString:
-		// Remove quotes if present
-		if strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
-			a.stringVal = value[1 : len(value)-1]
-		} else {
-			a.stringVal = value
+	case 't', 'f':
+		// Boolean
+		if value == "true" || value == "false" {
+			return Attribute{Atype: AttributeTypeBool, boolVal: value == "true"}, nil
 		}
+	}
 
-	case AttributeTypeFloat:
-		if strings.HasPrefix(value, "&") {
-			// IEEE754 hex format
-			hexVal := value[1:]
-			i64, err := strconv.ParseInt(hexVal, 16, 32)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
+	// Numeric integer or float
+	if end, isInt := scanNumber(value, 0); end == len(value) {
+		if isInt {
+			if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return Attribute{Atype: AttributeTypeInt, intVal: i}, nil
 			}
-			a.floatVal = float64(math.Float32frombits(uint32(i64)))
-		} else {
-			f, err := strconv.ParseFloat(value, 64)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
-			}
-			a.floatVal = f
 		}
 
-	case AttributeTypeFloat2:
-		vals := extractTupleValues(value)
-		if len(vals) != 2 {
-			return ErrParsingFailed
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return Attribute{Atype: AttributeTypeFloat, floatVal: f}, nil
 		}
-		for i, v := range vals {
-			f, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
-			}
-			a.float2Vals[i] = f
+	}
+
+	// Anything else is a string (includes tokens, owner_ptr, link_ptr, resource_tie)
+	return Attribute{Atype: AttributeTypeString, stringVal: value}, nil
+}
+
+// scanTuple scans a parenthesised tuple or placement value. It reports false
+// when the value is not a well formed tuple so the caller can fall back to a string.
+func scanTuple(s string) (Attribute, bool) {
+	var vals [4]float64
+	var ints [4]int64
+
+	n, allInts, hex, i, ok := scanTupleElems(s, 0, ',', vals[:], ints[:])
+	if !ok {
+		return Attribute{}, false
+	}
+
+	i = skipSpaces(s, i)
+	if i < len(s) {
+		// Only a placement may follow a tuple
+		if n != 3 || s[i] != '(' {
+			return Attribute{}, false
 		}
+		return scanPlacementRot(s, i, vals, hex)
+	}
+
+	attr := Attribute{}
+	attr.setHexMask(hex, n)
+	switch {
+	case n == 2 && allInts:
+		attr.Atype = AttributeTypeInt2
+		attr.int2Vals = [2]int64{ints[0], ints[1]}
+	case n == 3 && allInts:
+		attr.Atype = AttributeTypeInt3
+		attr.int3Vals = [3]int64{ints[0], ints[1], ints[2]}
+	case n == 4 && allInts:
+		attr.Atype = AttributeTypeInt4
+		attr.int4Vals = ints
+	case n == 2:
+		attr.Atype = AttributeTypeFloat2
+		attr.float2Vals = [2]float64{vals[0], vals[1]}
+	case n == 3:
+		attr.Atype = AttributeTypeFloat3
+		attr.float3Vals = [3]float64{vals[0], vals[1], vals[2]}
+	case n == 4:
+		attr.Atype = AttributeTypeFloat4
+		attr.float4Vals = vals
+	default:
+		return Attribute{}, false
+	}
+
+	return attr, true
+}
+
+// scanPlacementRot scans the (w; x, y, z) rotation part of a placement starting at s[i]
+// with posHex marking the position components written in hex
+func scanPlacementRot(s string, i int, pos [4]float64, posHex uint8) (Attribute, bool) {
+	var rot [4]float64
+
+	// The quaternion w component is separated by a semicolon
+	n, _, wHex, i, ok := scanTupleElems(s, i, ';', rot[:1], nil)
+	if !ok || n != 1 {
+		return Attribute{}, false
+	}
+
+	// The separator consumed the ';', step back so the rest scans as a tuple
+	n, _, xyzHex, i, ok := scanTupleElems(s, i-1, ',', rot[1:], nil)
+	if !ok || n != 3 || skipSpaces(s, i) != len(s) {
+		return Attribute{}, false
+	}
+
+	attr := Attribute{
+		Atype:        AttributeTypePlacement,
+		placementPos: [3]float64{pos[0], pos[1], pos[2]},
+		placementRot: rot,
+	}
+	attr.setHexMask(posHex|wHex<<3|xyzHex<<4, 7)
+	return attr, true
+}
+
+// setHexMask sets the hex form of the n floats of the attribute from hex, bit i for float i
+func (a *Attribute) setHexMask(hex uint8, n int) {
+	a.hexFloat = hex != 0
+	if a.hexFloat {
+		a.decimals = ^hex & (1<<n - 1)
+	}
+}
+
+// isHex reports if float i of the attribute is written in the &xxxxxxxx form
+func (a *Attribute) isHex(i int) bool {
+	return a.hexFloat && a.decimals&(1<<i) == 0
+}
+
+// scanTupleElems scans numbers separated by commas starting at the opening
+// delimiter s[i] and ending at the first closing parenthesis or at sep.
+// Parsed values are written to vals (and ints if not nil). It returns the
+// element count, whether every element was an integer, the elements written
+// in hex as bit i for element i and the index after the terminating byte.
+func scanTupleElems(s string, i int, sep byte, vals []float64, ints []int64) (n int, allInts bool, hex uint8, next int, ok bool) {
+	allInts = true
+	i++ // Skip the opening delimiter
 
-	case AttributeTypeFloat3:
-		vals := extractTupleValues(value)
-		if len(vals) != 3 {
-			return ErrParsingFailed
+	for {
+		if n == len(vals) {
+			return 0, false, 0, 0, false
 		}
-		for i, v := range vals {
-			f, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
+
+		i = skipSpaces(s, i)
+		start := i
+
+		var f float64
+		if i < len(s) && s[i] == '&' {
+			// IEEE754 hex float inside a tuple
+			for i++; i < len(s) && isHexDigit(s[i]); i++ {
 			}
-			a.float3Vals[i] = f
-		}
 
-	case AttributeTypeFloat4:
-		vals := extractTupleValues(value)
-		if len(vals) != 4 {
-			return ErrParsingFailed
-		}
-		for i, v := range vals {
-			f, err := strconv.ParseFloat(v, 64)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
+			var err error
+			if f, err = parseHexFloat(s[start:i]); err != nil {
+				return 0, false, 0, 0, false
 			}
-			a.float4Vals[i] = f
-		}
+			allInts = false
+			hex |= 1 << n
+		} else {
+			end, isInt := scanNumber(s, i)
+			if end == i {
+				return 0, false, 0, 0, false
+			}
+			i = end
 
-	case AttributeTypePlacement:
-		return a.parsePlacement(value)
+			var err error
+			if f, err = strconv.ParseFloat(s[start:i], 64); err != nil {
+				return 0, false, 0, 0, false
+			}
 
-	case AttributeTypeInt:
-		i, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return fmt.Errorf("%w: %v", ErrParsingFailed, err)
+			if isInt && ints != nil {
+				if ints[n], err = strconv.ParseInt(s[start:i], 10, 64); err != nil {
+					isInt = false
+				}
+			}
+			allInts = allInts && isInt
 		}
-		a.intVal = i
 
-	case AttributeTypeInt2:
-		vals := extractTupleValues(value)
-		if len(vals) != 2 {
-			return ErrParsingFailed
+		vals[n] = f
+		n++
+
+		i = skipSpaces(s, i)
+		if i >= len(s) {
+			return 0, false, 0, 0, false
 		}
-		for i, v := range vals {
-			n, err := strconv.ParseInt(v, 10, 64)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
+
+		switch s[i] {
+		case ')':
+			if sep != ',' {
+				return 0, false, 0, 0, false
+			}
+			return n, allInts, hex, i + 1, true
+		case sep:
+			if sep != ',' {
+				return n, false, hex, i + 1, true
 			}
-			a.int2Vals[i] = n
+			i++
+		default:
+			return 0, false, 0, 0, false
 		}
+	}
+}
 
-	case AttributeTypeInt3:
-		vals := extractTupleValues(value)
-		if len(vals) != 3 {
-			return ErrParsingFailed
-		}
-		for i, v := range vals {
-			n, err := strconv.ParseInt(v, 10, 64)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
-			}
-			a.int3Vals[i] = n
+// scanNumber scans a decimal number starting at s[i] and returns the index
+// after it. isInt is true when the number has no fraction or exponent.
+// If no number is found end equals i.
+func scanNumber(s string, i int) (end int, isInt bool) {
+	start := i
+	isInt = true
+
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+
+	digits := 0
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		digits++
+	}
+
+	if i < len(s) && s[i] == '.' {
+		isInt = false
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+			digits++
 		}
+	}
+
+	if digits == 0 {
+		return start, false
+	}
 
-	case AttributeTypeInt4:
-		vals := extractTupleValues(value)
-		if len(vals) != 4 {
-			return ErrParsingFailed
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '-' || s[j] == '+') {
+			j++
 		}
-		for i, v := range vals {
-			n, err := strconv.ParseInt(v, 10, 64)
-			if err != nil {
-				return fmt.Errorf("%w: %v", ErrParsingFailed, err)
+		if j < len(s) && isDigit(s[j]) {
+			for ; j < len(s) && isDigit(s[j]); j++ {
 			}
-			a.int4Vals[i] = n
+			i = j
+			isInt = false
 		}
-
-	case AttributeTypeBool:
-		a.boolVal = value == "true"
 	}
 
-	return nil
+	return i, isInt
 }
 
-func (a *Attribute) parsePlacement(value string) error {
-	// Format: (x, y, z) (w; x, y, z)
-	re := regexp.MustCompile(`^\s*\(\s*(-?\d+(?:\.\d+)?)\s*,\s*(-?\d+(?:\.\d+)?)\s*,\s*(-?\d+(?:\.\d+)?)\s*\)\s*\(\s*(-?\d+(?:\.\d+)?)\s*;\s*(-?\d+(?:\.\d+)?)\s*,\s*(-?\d+(?:\.\d+)?)\s*,\s*(-?\d+(?:\.\d+)?)\s*\)\s*$`)
-	matches := re.FindStringSubmatch(value)
-
-	if len(matches) != 8 {
-		return ErrParsingFailed
+// parseHexFloat parses the &xxxxxxxx IEEE754 representation of a float32
+func parseHexFloat(s string) (float64, error) {
+	u, err := strconv.ParseUint(strings.TrimPrefix(s, "&"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrParsingFailed, err)
 	}
+	return float64(math.Float32frombits(uint32(u))), nil
+}
 
-	// Parse position
-	for i := 1; i <= 3; i++ {
-		f, err := strconv.ParseFloat(matches[i], 64)
-		if err != nil {
-			return fmt.Errorf("%w: %v", ErrParsingFailed, err)
-		}
-		a.placementPos[i-1] = f
+func skipSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
 	}
+	return i
+}
 
-	// Parse rotation (quaternion)
-	for i := 4; i <= 7; i++ {
-		f, err := strconv.ParseFloat(matches[i], 64)
-		if err != nil {
-			return fmt.Errorf("%w: %v", ErrParsingFailed, err)
-		}
-		a.placementRot[i-4] = f
-	}
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
 
-	return nil
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
 }
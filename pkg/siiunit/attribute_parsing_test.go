@@ -0,0 +1,218 @@
+package siiunit
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestParseAttributeEdgeCases tests shapes the single pass scanner has to classify
+func TestParseAttributeEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantType AttributeType
+	}{
+		{name: "hex float", input: "&3f800000", wantType: AttributeTypeFloat},
+		{name: "negative hex float", input: "&bf800000", wantType: AttributeTypeFloat},
+		{name: "hex float3", input: "(&3f800000, &40000000, &40400000)", wantType: AttributeTypeFloat3},
+		{name: "hex placement", input: "(&3f800000, 0, 0) (&3f800000; 0, 0, 0)", wantType: AttributeTypePlacement},
+		{name: "exponent float", input: "1.5e3", wantType: AttributeTypeFloat},
+		{name: "int overflow falls back to float", input: "92233720368547758070", wantType: AttributeTypeFloat},
+		{name: "mixed tuple is float", input: "(1, 2.5)", wantType: AttributeTypeFloat2},
+		{name: "unclosed tuple", input: "(1, 2", wantType: AttributeTypeString},
+		{name: "five element tuple", input: "(1, 2, 3, 4, 5)", wantType: AttributeTypeString},
+		{name: "trailing garbage after tuple", input: "(1, 2) x", wantType: AttributeTypeString},
+		{name: "placement without semicolon", input: "(0, 0, 0) (1, 0, 0, 0)", wantType: AttributeTypeString},
+		{name: "token starting with t", input: "trailer", wantType: AttributeTypeString},
+		{name: "token starting with digit", input: "1st", wantType: AttributeTypeString},
+		{name: "nameless pointer", input: "_nameless.2cd.f1a8.5a70", wantType: AttributeTypeString},
+		{name: "lone minus", input: "-", wantType: AttributeTypeString},
+		{name: "empty", input: "", wantType: AttributeTypeString},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attr, err := parseAttribute(tt.input)
+			if err != nil {
+				t.Errorf("parseAttribute() error = %v", err)
+				return
+			}
+
+			if attr.Atype != tt.wantType {
+				t.Errorf("Expected %v, got %v", attributeTypeNames[tt.wantType], attributeTypeNames[attr.Atype])
+			}
+		})
+	}
+}
+
+// TestParseHexFloat tests the IEEE754 hex float values
+func TestParseHexFloat(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{input: "&3f800000", want: 1.0},
+		{input: "&bf800000", want: -1.0},
+		{input: "&40490fdb", want: float64(float32(3.1415927))},
+		{input: "&00000000", want: 0.0},
+	}
+
+	for _, tt := range tests {
+		attr, err := parseAttribute(tt.input)
+		if err != nil {
+			t.Errorf("parseAttribute(%q) error = %v", tt.input, err)
+			continue
+		}
+
+		val, err := attr.Float()
+		if err != nil {
+			t.Errorf("Float() error = %v", err)
+			continue
+		}
+
+		if val != tt.want {
+			t.Errorf("parseAttribute(%q).Float() = %v, want %v", tt.input, val, tt.want)
+		}
+	}
+
+	if _, err := parseAttribute("&xyz"); err == nil {
+		t.Error("Expected error for invalid hex float, got nil")
+	}
+}
+
+var benchmarkInputs = []struct {
+	atype AttributeType
+	input string
+}{
+	{AttributeTypeString, `"/def/vehicle/truck/volvo.fh16_2012/engine/d13c540.sii"`},
+	{AttributeTypeFloat, "&3f800000"},
+	{AttributeTypeFloat2, "(1.5, -2.25)"},
+	{AttributeTypeFloat3, "(-35478.76, 29.54, 7826.03)"},
+	{AttributeTypeFloat4, "(1.0, 0.0, 0.0, 0.0)"},
+	{AttributeTypePlacement, "(-35478.76, 29.54, 7826.03) (0.9998; 0, -0.0179, 0)"},
+	{AttributeTypeInt, "1523644"},
+	{AttributeTypeInt2, "(10, 22)"},
+	{AttributeTypeInt3, "(10, 22, 33)"},
+	{AttributeTypeInt4, "(10, 22, 33, 44)"},
+	{AttributeTypeBool, "true"},
+}
+
+// BenchmarkParseAttribute tracks ns/op and allocs/op for every attribute type
+func BenchmarkParseAttribute(b *testing.B) {
+	for _, bi := range benchmarkInputs {
+		b.Run(attributeTypeNames[bi.atype], func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				attr, err := parseAttribute(bi.input)
+				if err != nil || attr.Atype != bi.atype {
+					b.Fatalf("parseAttribute(%q) = %v, %v", bi.input, attr.Atype, err)
+				}
+			}
+		})
+	}
+
+	b.Run(attributeTypeNames[AttributeTypeArray], func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			attr := Attribute{}
+			attr.makeArray(4)
+			for range 4 {
+				if err := attr.appendToArray("_nameless.2cd.f1a8.5a70"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+const mixedHexSii = `SiiNunit
+{
+player : _nameless.1d4.8d36.7050 {
+ truck_placement: (-35478.76, 29.54, 7826.03) (&3f7ff9ce; 0.0, &3b6f2e1b, 0.0)
+ offset: (&3f800000, 0.1, &bf000000)
+ wear: &3e800000
+}
+
+}
+`
+
+// TestMixedHexRoundTrip tests values mixing hex and decimal floats keep both forms
+func TestMixedHexRoundTrip(t *testing.T) {
+	units, err := ParseAllUnits(strings.NewReader(mixedHexSii))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	if got := writeTestUnits(t, units); got != mixedHexSii {
+		t.Errorf("WriteUnits() =\n%s\nwant\n%s", got, mixedHexSii)
+	}
+
+	placement, _ := units[0].Attrs.Get("truck_placement")
+	if pos, _, _ := placement.Placement(); pos[0] != -35478.76 {
+		t.Errorf("position x = %v, want -35478.76", pos[0])
+	}
+
+	var js strings.Builder
+	if err := WriteUnitsJSON(&js, units); err != nil {
+		t.Fatalf("WriteUnitsJSON() error = %v", err)
+	}
+	fromJSON, err := ReadUnitsJSON(strings.NewReader(js.String()))
+	if err != nil {
+		t.Fatalf("ReadUnitsJSON() error = %v", err)
+	}
+	if got := writeTestUnits(t, fromJSON); got != mixedHexSii {
+		t.Errorf("JSON round trip =\n%s\nwant\n%s", got, mixedHexSii)
+	}
+
+	var yaml strings.Builder
+	if err := WriteUnitsYAML(&yaml, units); err != nil {
+		t.Fatalf("WriteUnitsYAML() error = %v", err)
+	}
+	if want := "  offset: [!hex 1.0, 0.1, !hex -0.5]\n"; !strings.Contains(yaml.String(), want) {
+		t.Errorf("WriteUnitsYAML() =\n%s\nwant a line %s", yaml.String(), want)
+	}
+	fromYAML, err := ReadUnitsYAML(strings.NewReader(yaml.String()))
+	if err != nil {
+		t.Fatalf("ReadUnitsYAML() error = %v", err)
+	}
+	if got := writeTestUnits(t, fromYAML); got != mixedHexSii {
+		t.Errorf("YAML round trip =\n%s\nwant\n%s", got, mixedHexSii)
+	}
+}
+
+// TestNonFiniteRoundTrip tests NaN and infinities survive SII and YAML
+func TestNonFiniteRoundTrip(t *testing.T) {
+	attrs := NewAttributes()
+	attrs.Set("nan", NewFloatAttribute(math.NaN()))
+	attrs.Set("inf", NewHexFloatAttribute(math.Inf(1)))
+	attrs.Set("vec", NewFloat3Attribute([3]float64{1.5, math.Inf(-1), 0}))
+	units := []Unit{{Utype: "bank", ID: "bank.player", Attrs: attrs}}
+
+	sii := writeTestUnits(t, units)
+	if want := " nan: &7fc00000\n inf: &7f800000\n vec: (1.5, &ff800000, 0.0)\n"; !strings.Contains(sii, want) {
+		t.Errorf("WriteUnits() =\n%s\nwant\n%s", sii, want)
+	}
+	parsed, err := ParseAllUnits(strings.NewReader(sii))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+	if f, err := GetAs[float64](parsed[0].Attrs, "nan"); err != nil || !math.IsNaN(f) {
+		t.Errorf("nan = %v, %v, want NaN", f, err)
+	}
+
+	var yaml strings.Builder
+	if err := WriteUnitsYAML(&yaml, units); err != nil {
+		t.Fatalf("WriteUnitsYAML() error = %v", err)
+	}
+	if want := "  nan: .nan\n  inf: !hex .inf\n  vec: [1.5, -.inf, 0.0]\n"; !strings.Contains(yaml.String(), want) {
+		t.Errorf("WriteUnitsYAML() =\n%s\nwant\n%s", yaml.String(), want)
+	}
+	fromYAML, err := ReadUnitsYAML(strings.NewReader(yaml.String()))
+	if err != nil {
+		t.Fatalf("ReadUnitsYAML() error = %v", err)
+	}
+	if got := writeTestUnits(t, fromYAML); got != sii {
+		t.Errorf("YAML round trip =\n%s\nwant\n%s", got, sii)
+	}
+}
@@ -731,10 +731,41 @@ func TestArrayOperations(t *testing.T) {
 			t.Errorf("AppendToArray() error = %v", err)
 		}
 
-		// Try to append an int - should fail
-		err = attr.appendToArray("42")
+		// Try to append a string - should fail
+		err = attr.appendToArray(`"42"`)
 		if err == nil {
-			t.Error("Expected error when appending int to float array, got nil")
+			t.Error("Expected error when appending string to float array, got nil")
+		}
+	})
+
+	t.Run("ints and floats widen to floats", func(t *testing.T) {
+		for _, values := range [][]string{{"1", "1.5", "2"}, {"1.5", "1", "2"}} {
+			want := map[string]float64{"1": 1, "1.5": 1.5, "2": 2}
+			attr := &Attribute{}
+			attr.makeArray(3)
+			for _, v := range values {
+				if err := attr.appendToArray(v); err != nil {
+					t.Fatalf("appendToArray(%s) error = %v", v, err)
+				}
+			}
+			if attr.arrayElemType != AttributeTypeFloat || len(attr.arrayVals) != 3 {
+				t.Fatalf("array of %v = %d %s elements, want 3 floats", values, len(attr.arrayVals), attributeTypeNames[attr.arrayElemType])
+			}
+			for i, elem := range attr.arrayVals {
+				if elem.Atype != AttributeTypeFloat || elem.floatVal != want[values[i]] {
+					t.Errorf("element %d = %s %v, want float %s", i, elem.TypeName(), elem.floatVal, values[i])
+				}
+			}
+		}
+
+		attr := &Attribute{}
+		attr.makeArray(2)
+		attr.appendToArray("(0, 0, 0)")
+		if err := attr.appendToArray("(1.5, 0, 2)"); err != nil || attr.arrayElemType != AttributeTypeFloat3 || attr.arrayVals[0].float3Vals != [3]float64{} {
+			t.Errorf("int3 then float3 = %v %s, want float3", err, attributeTypeNames[attr.arrayElemType])
+		}
+		if err := attr.appendToArray("(1, 2)"); err == nil {
+			t.Error("Expected error when appending int2 to float3 array, got nil")
 		}
 	})
 
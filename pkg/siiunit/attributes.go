@@ -1,12 +1,36 @@
 package siiunit
 
 import (
+	"errors"
 	"fmt"
 	"iter"
+	"maps"
+	"reflect"
+	"slices"
 )
 
+var (
+	ErrAttributeNotFound = errors.New("attribute not found")
+	ErrAttributeExists   = errors.New("attribute already exists")
+	ErrIndexOutOfRange   = errors.New("array index out of range")
+)
+
+// Attributes holds the attributes of a unit in the order they were added.
+// The zero value is an empty set ready to use.
 type Attributes struct {
 	attrs map[string]*Attribute
+	keys  []string // Keys in insertion order
+
+	comments map[string]string // Comment lines above an attribute, joined by newlines
+
+	// changed is called with the key of every attribute that is set, deleted or renamed
+	// after the change, the document uses it to keep its reference index up to date
+	changed func(key string)
+}
+
+// NewAttributes creates an empty attribute set
+func NewAttributes() *Attributes {
+	return newAttributes()
 }
 
 func newAttributes() *Attributes {
@@ -15,29 +39,307 @@ func newAttributes() *Attributes {
 	}
 }
 
-func (as *Attributes) addAttribute(key, val string) error {
+// addAttribute parses val and stores it under key, interning both through symbols
+func (as *Attributes) addAttribute(key, val string, symbols *SymbolTable) error {
 	attr, err := newAttribute(val)
 	if err != nil {
 		return fmt.Errorf("failed to add attribute %s: %w", key, err)
 	}
 
+	symbols.internAttribute(attr)
+
+	as.set(symbols.Intern(key), attr)
+	return nil
+}
+
+// set stores attr under key, new keys are added after the existing ones
+func (as *Attributes) set(key string, attr *Attribute) {
+	if as.attrs == nil {
+		as.attrs = make(map[string]*Attribute)
+	}
+	if _, exists := as.attrs[key]; !exists {
+		as.keys = append(as.keys, key)
+	}
+	as.attrs[key] = attr
+	as.notify(key)
+}
+
+// insertKey stores attr under a new key at position i of the key order
+func (as *Attributes) insertKey(i int, key string, attr *Attribute) {
+	if as.attrs == nil {
+		as.attrs = make(map[string]*Attribute)
+	}
+	as.keys = slices.Insert(as.keys, i, key)
 	as.attrs[key] = attr
+	as.notify(key)
+}
+
+// notify reports a change of the attribute stored under key
+func (as *Attributes) notify(key string) {
+	if as.changed != nil {
+		as.changed(key)
+	}
+}
+
+// restore replaces all attributes with a copy of saved and reports every key
+// that was removed or restored
+func (as *Attributes) restore(saved *Attributes) {
+	old := as.keys
+	clone := saved.Clone()
+	as.attrs, as.keys, as.comments = clone.attrs, clone.keys, clone.comments
+
+	for _, key := range old {
+		if !as.Has(key) {
+			as.notify(key)
+		}
+	}
+	for _, key := range as.keys {
+		as.notify(key)
+	}
+}
+
+// Clone returns a deep copy of the attributes
+func (as *Attributes) Clone() *Attributes {
+	clone := newAttributes()
+	for key, attr := range as.All() {
+		attr = attr.clone()
+		clone.set(key, &attr)
+	}
+	if as != nil && as.comments != nil {
+		clone.comments = maps.Clone(as.comments)
+	}
+	return clone
+}
+
+// Comment returns the comment written above the attribute stored under key
+func (as *Attributes) Comment(key string) string {
+	if as == nil {
+		return ""
+	}
+	return as.comments[key]
+}
+
+// SetComment sets the comment written above the attribute stored under key, lines are
+// separated by newlines. An empty comment removes it.
+func (as *Attributes) SetComment(key, comment string) error {
+	if _, ok := as.attrs[key]; !ok {
+		return fmt.Errorf("%w: %s", ErrAttributeNotFound, key)
+	}
+
+	if comment == "" {
+		delete(as.comments, key)
+		return nil
+	}
+	if as.comments == nil {
+		as.comments = make(map[string]string)
+	}
+	as.comments[key] = comment
 	return nil
 }
 
+// lookup returns the stored attribute, it's safe to call on a nil set
+func (as *Attributes) lookup(key string) (*Attribute, bool) {
+	if as == nil {
+		return nil, false
+	}
+	attr, ok := as.attrs[key]
+	return attr, ok
+}
+
+// Get returns the attribute stored under attrKey, ok is false if there is none
 func (as *Attributes) Get(attrKey string) (Attribute, bool) {
-	attr, ok := as.attrs[attrKey]
-	return *attr, ok
+	attr, ok := as.lookup(attrKey)
+	if !ok {
+		return Attribute{}, false
+	}
+	return *attr, true
 }
 
-// All returns an iterator over all attribute key-value pairs.
+// Has reports if there is an attribute stored under key
+func (as *Attributes) Has(key string) bool {
+	_, ok := as.lookup(key)
+	return ok
+}
+
+// Len returns the number of attributes
+func (as *Attributes) Len() int {
+	if as == nil {
+		return 0
+	}
+	return len(as.keys)
+}
+
+// Keys returns the attribute keys in order
+func (as *Attributes) Keys() []string {
+	if as == nil {
+		return nil
+	}
+	return slices.Clone(as.keys)
+}
+
+// All returns an iterator over all attribute key-value pairs in the order they were added.
 // Usage: for key, attr := range attrs.All() { ... }
 func (as *Attributes) All() iter.Seq2[string, Attribute] {
 	return func(yield func(string, Attribute) bool) {
-		for k, v := range as.attrs {
-			if !yield(k, *v) {
+		if as == nil {
+			return
+		}
+		for _, k := range as.keys {
+			if !yield(k, *as.attrs[k]) {
 				return
 			}
 		}
 	}
 }
+
+// Set stores a copy of attr under key. A new key is added after the existing ones,
+// an existing key keeps its position. Arrays must hold elements of a single type.
+func (as *Attributes) Set(key string, attr Attribute) error {
+	if err := attr.validate(); err != nil {
+		return fmt.Errorf("failed to set attribute %s: %w", key, err)
+	}
+
+	attr = attr.clone()
+	as.set(key, &attr)
+	return nil
+}
+
+// Delete removes the attribute stored under key, it reports false if there was none
+func (as *Attributes) Delete(key string) bool {
+	if _, ok := as.attrs[key]; !ok {
+		return false
+	}
+
+	delete(as.attrs, key)
+	delete(as.comments, key)
+	as.keys = slices.DeleteFunc(as.keys, func(k string) bool { return k == key })
+	as.notify(key)
+	return true
+}
+
+// Rename moves the attribute stored under oldKey to newKey, keeping its position
+func (as *Attributes) Rename(oldKey, newKey string) error {
+	attr, ok := as.attrs[oldKey]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrAttributeNotFound, oldKey)
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	if _, exists := as.attrs[newKey]; exists {
+		return fmt.Errorf("%w: %s", ErrAttributeExists, newKey)
+	}
+
+	delete(as.attrs, oldKey)
+	as.attrs[newKey] = attr
+	if comment, ok := as.comments[oldKey]; ok {
+		delete(as.comments, oldKey)
+		as.comments[newKey] = comment
+	}
+	as.keys[slices.Index(as.keys, oldKey)] = newKey
+	as.notify(oldKey)
+	as.notify(newKey)
+	return nil
+}
+
+// AppendTo appends elem to the array stored under key
+func (as *Attributes) AppendTo(key string, elem Attribute) error {
+	arr, err := as.array(key)
+	if err != nil {
+		return err
+	}
+	return as.InsertAt(key, len(arr.arrayVals), elem)
+}
+
+// InsertAt inserts elem into the array stored under key before index.
+// index may be the array length to append.
+func (as *Attributes) InsertAt(key string, index int, elem Attribute) error {
+	arr, err := as.array(key)
+	if err != nil {
+		return err
+	}
+	if err := arr.InsertAt(index, elem); err != nil {
+		return err
+	}
+
+	as.notify(key)
+	return nil
+}
+
+// RemoveAt removes the element at index from the array stored under key
+func (as *Attributes) RemoveAt(key string, index int) error {
+	arr, err := as.array(key)
+	if err != nil {
+		return err
+	}
+	if err := arr.RemoveAt(index); err != nil {
+		return err
+	}
+
+	as.notify(key)
+	return nil
+}
+
+// array returns the array attribute stored under key
+func (as *Attributes) array(key string) (*Attribute, error) {
+	attr, ok := as.lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAttributeNotFound, key)
+	}
+	if attr.Atype != AttributeTypeArray {
+		return nil, fmt.Errorf("%w: %s", ErrNotAnArray, key)
+	}
+	return attr, nil
+}
+
+// GetAs returns the attribute stored under key converted to T, using the same
+// mapping as Unmarshal. T can be any scalar, vector, Placement or slice type, e.g.
+//
+//	money, err := siiunit.GetAs[int64](attrs, "money_account")
+//	dealers, err := siiunit.GetAs[[]string](attrs, "unlocked_dealers")
+//
+// A missing key returns ErrAttributeNotFound, a type mismatch an *UnmarshalTypeError.
+func GetAs[T any](attrs *Attributes, key string) (T, error) {
+	return getAs[T](attrs, key, false)
+}
+
+// GetAsLenient is GetAs but also coerces 0/1 to bools and ints too large to convert
+// exactly to floats, see Attribute.AsBool
+func GetAsLenient[T any](attrs *Attributes, key string) (T, error) {
+	return getAs[T](attrs, key, true)
+}
+
+func getAs[T any](attrs *Attributes, key string, lenient bool) (T, error) {
+	var v T
+
+	attr, ok := attrs.lookup(key)
+	if !ok {
+		return v, fmt.Errorf("%w: %s", ErrAttributeNotFound, key)
+	}
+
+	d := &decoder{visiting: make(map[string]bool), lenient: lenient}
+	if err := d.decodeAttr(attr, key, reflect.ValueOf(&v).Elem()); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return v, nil
+}
+
+// MustGet is GetAs but panics if the attribute is missing or has another type
+func MustGet[T any](attrs *Attributes, key string) T {
+	v, err := GetAs[T](attrs, key)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// GetOr is GetAs but returns def if the attribute is missing or has another type
+func GetOr[T any](attrs *Attributes, key string, def T) T {
+	v, err := GetAs[T](attrs, key)
+	if err != nil {
+		return def
+	}
+	return v
+}
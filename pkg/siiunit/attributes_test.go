@@ -0,0 +1,393 @@
+package siiunit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAttributesGetMissing tests that missing keys don't panic
+func TestAttributesGetMissing(t *testing.T) {
+	attrs := newAttributes()
+
+	attr, ok := attrs.Get("missing")
+	if ok || attr.Atype != AttributeTypeString {
+		t.Errorf("Get() = %v, %v, want zero attribute and false", attr, ok)
+	}
+}
+
+// TestGetAs tests the generic accessors for every attribute type
+func TestGetAs(t *testing.T) {
+	attrs := newAttributes()
+	values := map[string]string{
+		"name":      `"Volvo FH16"`,
+		"cargo":     "cargo.wood",
+		"money":     "1523644",
+		"fuel":      "&3f000000",
+		"pos2":      "(1.5, 2.5)",
+		"pos3":      "(1.5, 2.5, 3.5)",
+		"pos4":      "(1.5, 2.5, 3.5, 4.5)",
+		"size2":     "(1, 2)",
+		"size3":     "(1, 2, 3)",
+		"size4":     "(1, 2, 3, 4)",
+		"placement": "(1, 2, 3) (1; 0, 0, 0)",
+		"broken":    "true",
+	}
+	for key, val := range values {
+		attrs.addAttribute(key, val, nil)
+	}
+
+	attrs.set("dealers", &Attribute{})
+	attrs.attrs["dealers"].makeArray(2)
+	attrs.attrs["dealers"].appendToArray("volvo_dlr")
+	attrs.attrs["dealers"].appendToArray("scania_fac")
+
+	attrs.set("points", &Attribute{})
+	attrs.attrs["points"].makeArray(2)
+	attrs.attrs["points"].appendToArray("(1, 2, 3)")
+	attrs.attrs["points"].appendToArray("(4, 5, 6)")
+
+	check := func(name string, got, want any, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("GetAs(%s) error = %v", name, err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAs(%s) = %v, want %v", name, got, want)
+		}
+	}
+
+	name, err := GetAs[string](attrs, "name")
+	check("name", name, "Volvo FH16", err)
+	cargo, err := GetAs[string](attrs, "cargo")
+	check("cargo", cargo, "cargo.wood", err)
+	money, err := GetAs[int64](attrs, "money")
+	check("money", money, int64(1523644), err)
+	fuel, err := GetAs[float32](attrs, "fuel")
+	check("fuel", fuel, float32(0.5), err)
+	pos2, err := GetAs[[2]float64](attrs, "pos2")
+	check("pos2", pos2, [2]float64{1.5, 2.5}, err)
+	pos3, err := GetAs[[3]float64](attrs, "pos3")
+	check("pos3", pos3, [3]float64{1.5, 2.5, 3.5}, err)
+	pos4, err := GetAs[[4]float64](attrs, "pos4")
+	check("pos4", pos4, [4]float64{1.5, 2.5, 3.5, 4.5}, err)
+	size2, err := GetAs[[2]int64](attrs, "size2")
+	check("size2", size2, [2]int64{1, 2}, err)
+	size3, err := GetAs[[3]int](attrs, "size3")
+	check("size3", size3, [3]int{1, 2, 3}, err)
+	size4, err := GetAs[[4]int64](attrs, "size4")
+	check("size4", size4, [4]int64{1, 2, 3, 4}, err)
+	placement, err := GetAs[Placement](attrs, "placement")
+	check("placement", placement, Placement{Pos: [3]float64{1, 2, 3}, Rot: [4]float64{1, 0, 0, 0}}, err)
+	broken, err := GetAs[bool](attrs, "broken")
+	check("broken", broken, true, err)
+	dealers, err := GetAs[[]string](attrs, "dealers")
+	check("dealers", dealers, []string{"volvo_dlr", "scania_fac"}, err)
+	points, err := GetAs[[][3]int64](attrs, "points")
+	check("points", points, [][3]int64{{1, 2, 3}, {4, 5, 6}}, err)
+	elems, err := GetAs[[]Attribute](attrs, "points")
+	if err != nil || len(elems) != 2 || elems[1].Atype != AttributeTypeInt3 {
+		t.Errorf("GetAs([]Attribute) = %v, %v", elems, err)
+	}
+
+	if _, err := GetAs[int64](attrs, "missing"); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("GetAs() error = %v, want ErrAttributeNotFound", err)
+	}
+
+	if v, err := GetAs[int64](attrs, "name"); !errors.Is(err, ErrInvalidType) || v != 0 {
+		t.Errorf("GetAs() = %v, %v, want ErrInvalidType", v, err)
+	}
+
+	if got := GetOr(attrs, "missing", int64(7)); got != 7 {
+		t.Errorf("GetOr() = %v, want 7", got)
+	}
+
+	if got := GetOr(attrs, "money", int64(7)); got != 1523644 {
+		t.Errorf("GetOr() = %v, want 1523644", got)
+	}
+
+	if got := MustGet[bool](attrs, "broken"); !got {
+		t.Errorf("MustGet() = %v, want true", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGet() on a missing key did not panic")
+		}
+	}()
+	MustGet[bool](attrs, "missing")
+}
+
+// TestLenientAccessors tests coercion between compatible attribute types
+func TestLenientAccessors(t *testing.T) {
+	mustParse := func(s string) *Attribute {
+		a, err := newAttribute(s)
+		if err != nil {
+			t.Fatalf("newAttribute(%q) error = %v", s, err)
+		}
+		return a
+	}
+
+	if v, err := mustParse("42").AsFloat(); err != nil || v != 42 {
+		t.Errorf("AsFloat() on int = %v, %v", v, err)
+	}
+	if v, err := mustParse("&3f800000").AsFloat(); err != nil || v != 1 {
+		t.Errorf("AsFloat() on hex float = %v, %v", v, err)
+	}
+	if v, err := mustParse("(1, 2)").AsFloat2(); err != nil || v != [2]float64{1, 2} {
+		t.Errorf("AsFloat2() on int2 = %v, %v", v, err)
+	}
+	if v, err := mustParse("(1, 2, 3)").AsFloat3(); err != nil || v != [3]float64{1, 2, 3} {
+		t.Errorf("AsFloat3() on int3 = %v, %v", v, err)
+	}
+	if v, err := mustParse("(1, 2, 3, 4)").AsFloat4(); err != nil || v != [4]float64{1, 2, 3, 4} {
+		t.Errorf("AsFloat4() on int4 = %v, %v", v, err)
+	}
+	if v, err := mustParse("1").AsBool(); err != nil || !v {
+		t.Errorf("AsBool() on 1 = %v, %v", v, err)
+	}
+	if v, err := mustParse("false").AsBool(); err != nil || v {
+		t.Errorf("AsBool() on false = %v, %v", v, err)
+	}
+
+	failing := []func() error{
+		func() error { _, err := mustParse("2").AsBool(); return err },
+		func() error { _, err := mustParse(`"1.5"`).AsFloat(); return err },
+		func() error { _, err := mustParse("(1, 2)").AsFloat3(); return err },
+		func() error { _, err := mustParse("true").AsFloat(); return err },
+	}
+	for i, f := range failing {
+		if err := f(); err != ErrInvalidType {
+			t.Errorf("failing case %d error = %v, want ErrInvalidType", i, err)
+		}
+	}
+
+	// The strict accessors are unchanged
+	if _, err := mustParse("42").Float(); err != ErrInvalidType {
+		t.Errorf("Float() on int error = %v, want ErrInvalidType", err)
+	}
+}
+
+// TestLenientDecoding tests ints widen to floats by default and the lenient tag option
+func TestLenientDecoding(t *testing.T) {
+	unit := Unit{Attrs: newAttributes()}
+	unit.Attrs.addAttribute("pos", "(1, 2, 3)", nil)
+	unit.Attrs.addAttribute("speed", "80", nil)
+	unit.Attrs.addAttribute("enabled", "1", nil)
+	unit.Attrs.addAttribute("huge", "9007199254740993", nil)
+
+	var strict struct {
+		Pos   [3]float64 `sii:"pos"`
+		Vec   testVec3   `sii:"pos"`
+		Speed float32    `sii:"speed"`
+	}
+	if err := Unmarshal(unit, &strict); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if strict.Pos != [3]float64{1, 2, 3} || strict.Vec != (testVec3{1, 2, 3}) || strict.Speed != 80 {
+		t.Errorf("Unmarshal() = %+v", strict)
+	}
+
+	var enabled struct {
+		Enabled bool `sii:"enabled"`
+	}
+	if err := Unmarshal(unit, &enabled); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("Unmarshal() of 1 into a bool error = %v, want ErrInvalidType", err)
+	}
+	if _, err := GetAs[float64](unit.Attrs, "huge"); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("GetAs() of an int above 2^53 error = %v, want ErrInvalidType", err)
+	}
+
+	var lenient struct {
+		Pos     testVec3 `sii:"pos,lenient"`
+		Speed   float32  `sii:"speed,lenient"`
+		Enabled bool     `sii:"enabled,lenient"`
+	}
+	if err := Unmarshal(unit, &lenient); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if lenient.Pos != (testVec3{1, 2, 3}) || lenient.Speed != 80 || !lenient.Enabled {
+		t.Errorf("Unmarshal() = %+v", lenient)
+	}
+
+	if v, err := GetAs[[3]float64](unit.Attrs, "pos"); err != nil || v != [3]float64{1, 2, 3} {
+		t.Errorf("GetAs() = %v, %v", v, err)
+	}
+	if v, err := GetAsLenient[bool](unit.Attrs, "enabled"); err != nil || !v {
+		t.Errorf("GetAsLenient() = %v, %v", v, err)
+	}
+}
+
+// TestAttributesEditing tests Set, Delete and Rename keep the attribute order
+func TestAttributesEditing(t *testing.T) {
+	attrs := NewAttributes()
+	attrs.Set("money_account", NewIntAttribute(1523644))
+	attrs.Set("hq_city", NewTokenAttribute("berlin"))
+	attrs.Set("name", NewStringAttribute("Volvo FH16"))
+
+	// Overwriting a key keeps its position
+	attrs.Set("hq_city", NewTokenAttribute("paris"))
+	if got, want := attrs.Keys(), []string{"money_account", "hq_city", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+	if city, _ := GetAs[string](attrs, "hq_city"); city != "paris" {
+		t.Errorf("GetAs(hq_city) = %v, want paris", city)
+	}
+
+	if err := attrs.Rename("hq_city", "home_city"); err != nil {
+		t.Errorf("Rename() error = %v", err)
+	}
+	if got, want := attrs.Keys(), []string{"money_account", "home_city", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() after Rename = %v, want %v", got, want)
+	}
+	if err := attrs.Rename("missing", "other"); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("Rename(missing) error = %v, want %v", err, ErrAttributeNotFound)
+	}
+	if err := attrs.Rename("name", "money_account"); !errors.Is(err, ErrAttributeExists) {
+		t.Errorf("Rename(existing) error = %v, want %v", err, ErrAttributeExists)
+	}
+
+	if !attrs.Delete("money_account") || attrs.Delete("money_account") {
+		t.Error("Delete() should only succeed once")
+	}
+	if attrs.Has("money_account") || attrs.Len() != 2 {
+		t.Errorf("Delete() left %v", attrs.Keys())
+	}
+
+	// Set stores a copy, changing the source array afterwards doesn't leak in
+	arr, err := NewArrayAttribute(NewTokenAttribute("volvo_dlr"))
+	if err != nil {
+		t.Fatalf("NewArrayAttribute() error = %v", err)
+	}
+	attrs.Set("dealers", arr)
+	arr.arrayVals[0] = NewTokenAttribute("scania_fac")
+	if dealers, _ := GetAs[[]string](attrs, "dealers"); !reflect.DeepEqual(dealers, []string{"volvo_dlr"}) {
+		t.Errorf("GetAs(dealers) = %v, want [volvo_dlr]", dealers)
+	}
+
+	// Arrays with mixed element types are rejected
+	arr.arrayVals = append(arr.arrayVals, NewIntAttribute(1))
+	if err := attrs.Set("dealers", arr); err == nil {
+		t.Error("Set() with mixed array elements should fail")
+	}
+}
+
+// TestAttributesZeroValue tests an Attributes zero value can be edited without NewAttributes
+func TestAttributesZeroValue(t *testing.T) {
+	var attrs Attributes
+	if attrs.Delete("missing") {
+		t.Error("Delete() on an empty set should fail")
+	}
+	if err := attrs.SetComment("missing", "x"); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("SetComment() error = %v, want %v", err, ErrAttributeNotFound)
+	}
+
+	if err := attrs.Set("money_account", NewIntAttribute(100)); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := attrs.Rename("money_account", "money"); err != nil {
+		t.Errorf("Rename() error = %v", err)
+	}
+	if err := attrs.SetComment("money", "In euro"); err != nil || attrs.Comment("money") != "In euro" {
+		t.Errorf("SetComment() error = %v, comment %q", err, attrs.Comment("money"))
+	}
+
+	unit := Unit{Utype: "bank", ID: "bank.one", Attrs: &attrs}
+	if got := writeTestUnits(t, []Unit{unit}); !strings.Contains(got, " # In euro\n money: 100\n") {
+		t.Errorf("WriteUnits() = %s", got)
+	}
+}
+
+// TestAttributesArrayEditing tests the array operations and their element type checks
+func TestAttributesArrayEditing(t *testing.T) {
+	attrs := NewAttributes()
+	attrs.Set("points", NewInt3Attribute([3]int64{1, 2, 3}))
+
+	if err := attrs.AppendTo("points", NewInt3Attribute([3]int64{})); !errors.Is(err, ErrNotAnArray) {
+		t.Errorf("AppendTo(non array) error = %v, want %v", err, ErrNotAnArray)
+	}
+	if err := attrs.AppendTo("missing", NewIntAttribute(1)); !errors.Is(err, ErrAttributeNotFound) {
+		t.Errorf("AppendTo(missing) error = %v, want %v", err, ErrAttributeNotFound)
+	}
+
+	empty, _ := NewArrayAttribute()
+	attrs.Set("dealers", empty)
+
+	steps := []struct {
+		name string
+		op   func() error
+		want []string
+	}{
+		{name: "append to empty", op: func() error { return attrs.AppendTo("dealers", NewTokenAttribute("b")) }, want: []string{"b"}},
+		{name: "append", op: func() error { return attrs.AppendTo("dealers", NewTokenAttribute("d")) }, want: []string{"b", "d"}},
+		{name: "insert front", op: func() error { return attrs.InsertAt("dealers", 0, NewTokenAttribute("a")) }, want: []string{"a", "b", "d"}},
+		{name: "insert middle", op: func() error { return attrs.InsertAt("dealers", 2, NewTokenAttribute("c")) }, want: []string{"a", "b", "c", "d"}},
+		{name: "remove", op: func() error { return attrs.RemoveAt("dealers", 1) }, want: []string{"a", "c", "d"}},
+	}
+
+	for _, step := range steps {
+		if err := step.op(); err != nil {
+			t.Errorf("%s: error = %v", step.name, err)
+			continue
+		}
+		got, _ := GetAs[[]string](attrs, "dealers")
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: dealers = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	if err := attrs.AppendTo("dealers", NewIntAttribute(1)); err == nil {
+		t.Error("AppendTo() with a different element type should fail")
+	}
+	if err := attrs.InsertAt("dealers", 4, NewTokenAttribute("e")); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("InsertAt(4) error = %v, want %v", err, ErrIndexOutOfRange)
+	}
+	if err := attrs.RemoveAt("dealers", 3); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("RemoveAt(3) error = %v, want %v", err, ErrIndexOutOfRange)
+	}
+
+	if _, err := NewArrayAttribute(NewIntAttribute(1), NewTokenAttribute("a")); err == nil {
+		t.Error("NewArrayAttribute() with mixed elements should fail")
+	}
+	if arr, err := NewArrayAttribute(NewIntAttribute(1), NewFloatAttribute(1.5)); err != nil || arr.arrayElemType != AttributeTypeFloat {
+		t.Errorf("NewArrayAttribute() of an int and a float = %v, %v, want a float array", arr.TypeName(), err)
+	}
+}
+
+// TestAttributeConstructors tests the typed constructors write back the way they read
+func TestAttributeConstructors(t *testing.T) {
+	tests := []struct {
+		attr Attribute
+		want string
+	}{
+		{attr: NewStringAttribute("Volvo FH16"), want: `"Volvo FH16"`},
+		{attr: NewTokenAttribute("cargo.wood"), want: "cargo.wood"},
+		{attr: NewNullAttribute(), want: "null"},
+		{attr: NewFloatAttribute(2), want: "2.0"},
+		{attr: NewHexFloatAttribute(1), want: "&3f800000"},
+		{attr: NewFloat2Attribute([2]float64{1, 2.5}), want: "(1.0, 2.5)"},
+		{attr: NewFloat3Attribute([3]float64{1, 2, 3}), want: "(1.0, 2.0, 3.0)"},
+		{attr: NewFloat4Attribute([4]float64{1, 2, 3, 4}), want: "(1.0, 2.0, 3.0, 4.0)"},
+		{attr: NewPlacementAttribute([3]float64{1, 2, 3}, [4]float64{1, 0, 0, 0}), want: "(1.0, 2.0, 3.0) (1.0; 0.0, 0.0, 0.0)"},
+		{attr: NewIntAttribute(-5), want: "-5"},
+		{attr: NewInt2Attribute([2]int64{1, 2}), want: "(1, 2)"},
+		{attr: NewInt3Attribute([3]int64{1, 2, 3}), want: "(1, 2, 3)"},
+		{attr: NewInt4Attribute([4]int64{1, 2, 3, 4}), want: "(1, 2, 3, 4)"},
+		{attr: NewBoolAttribute(true), want: "true"},
+	}
+
+	for _, tt := range tests {
+		got := tt.attr.siiValue()
+		if got != tt.want {
+			t.Errorf("siiValue() = %v, want %v", got, tt.want)
+			continue
+		}
+
+		parsed, err := parseAttribute(got)
+		if err != nil || parsed.Atype != tt.attr.Atype {
+			t.Errorf("parseAttribute(%q) = %v, %v, want %v", got, parsed.TypeName(), err, tt.attr.TypeName())
+		}
+	}
+}
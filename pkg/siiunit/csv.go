@@ -0,0 +1,190 @@
+package siiunit
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+type CSVOption func(*csvOptions) error
+
+type csvOptions struct {
+	comma    rune
+	arraySep string
+	explode  bool
+}
+
+// OptCSVComma sets the field delimiter, e.g. '\t' for TSV. The default is ','.
+func OptCSVComma(comma rune) CSVOption {
+	return func(co *csvOptions) error {
+		if comma == '"' || comma == '\r' || comma == '\n' || comma == 0 {
+			return fmt.Errorf("OptCSVComma: invalid delimiter %q", comma)
+		}
+		co.comma = comma
+		return nil
+	}
+}
+
+// OptCSVArraySep sets the separator array elements are joined with, the default is ";"
+func OptCSVArraySep(sep string) CSVOption {
+	return func(co *csvOptions) error {
+		if sep == "" {
+			return fmt.Errorf("OptCSVArraySep: empty separator")
+		}
+		co.arraySep = sep
+		return nil
+	}
+}
+
+// OptCSVExplode writes one row per array element instead of joining the elements.
+// Arrays of a unit are zipped, row i holds element i of every array.
+func OptCSVExplode() CSVOption {
+	return func(co *csvOptions) error {
+		co.explode = true
+		return nil
+	}
+}
+
+// WriteUnitsCSV writes the units of the given type as CSV, one row per unit with an id
+// column followed by the union of the attribute keys in first seen order. Tuples and
+// placements are split into columns like for the SQL export, key_x, key_y, key_z.
+// An attribute named id is written to an attr_id column. Floats are written in decimal
+// and missing attributes as empty fields. Joined array elements holding the separator
+// or starting with a double quote are double quoted with inner quotes doubled, so the
+// field can be split back apart.
+func WriteUnitsCSV(w io.Writer, units []Unit, utype string, opts ...CSVOption) error {
+	options := &csvOptions{comma: ',', arraySep: ";"}
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidOption, err)
+		}
+	}
+
+	var matched []Unit
+	for _, unit := range units {
+		if unit.Utype == utype {
+			matched = append(matched, unit)
+		}
+	}
+	arrays := arrayKeys(matched)
+	keys := attributeKeys(matched)
+
+	elemTypes := make(map[string]AttributeType)
+	for _, unit := range matched {
+		for key, attr := range unit.Attrs.All() {
+			if attr.Atype == AttributeTypeArray && len(attr.arrayVals) > 0 {
+				elemTypes[key] = attr.arrayElemType
+			}
+		}
+	}
+
+	columns := []string{"id"}
+	seen := map[string]bool{"id": true}
+	addColumn := func(column string) {
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+
+	var rows []map[string]string
+	for _, unit := range matched {
+		row := map[string]string{"id": unit.ID}
+		elems := make(map[string][]string) // Array columns to their element values
+		length := 0
+
+		for key, attr := range unit.Attrs.All() {
+			if !arrays[key] {
+				flattenAttribute(key, &attr, func(column string, value any) {
+					column = attrColumn(column, keys, "id")
+					addColumn(column)
+					row[column] = csvValue(value)
+				})
+				continue
+			}
+
+			// Empty arrays still get the columns of their elements, strings if unknown
+			shape := Attribute{Atype: elemTypes[key]}
+			flattenAttribute(key, &shape, func(column string, _ any) { addColumn(attrColumn(column, keys, "id")) })
+			if attr.Atype != AttributeTypeArray {
+				continue
+			}
+			for i := range attr.arrayVals {
+				flattenAttribute(key, &attr.arrayVals[i], func(column string, value any) {
+					column = attrColumn(column, keys, "id")
+					addColumn(column)
+					elems[column] = append(elems[column], csvValue(value))
+				})
+			}
+			length = max(length, len(attr.arrayVals))
+		}
+
+		if !options.explode || length == 0 {
+			for column, values := range elems {
+				row[column] = joinCSVElems(values, options.arraySep)
+			}
+			rows = append(rows, row)
+			continue
+		}
+
+		for i := range length {
+			elemRow := maps.Clone(row)
+			for column, values := range elems {
+				if i < len(values) {
+					elemRow[column] = values[i]
+				}
+			}
+			rows = append(rows, elemRow)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = options.comma
+
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// joinCSVElems joins array elements with sep, quoting the ones that wouldn't split back
+func joinCSVElems(values []string, sep string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		if strings.Contains(v, sep) || strings.HasPrefix(v, `"`) {
+			v = `"` + strings.ReplaceAll(v, `"`, `""`) + `"`
+		}
+		quoted[i] = v
+	}
+	return strings.Join(quoted, sep)
+}
+
+// csvValue formats a flattened value
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
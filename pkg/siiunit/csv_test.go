@@ -0,0 +1,125 @@
+package siiunit
+
+import (
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const csvTestSii = `SiiNunit
+{
+vehicle : _nameless.1d4.8d36.7060 {
+ odometer: 512345
+ position: (1.5, 2.0, &40400000)
+ accessories: 2
+ accessories[0]: _nameless.1d4.8d36.7080
+ accessories[1]: _nameless.1d4.8d36.7090
+ wheels: 0
+}
+vehicle : vehicle.second {
+ odometer: 1200
+ wheels: 2
+ wheels[0]: (1, 2)
+ wheels[1]: (3, 4)
+ license_plate: "AB 123, CD"
+}
+vehicle_accessory : _nameless.1d4.8d36.7080 {
+ data_path: "/def/vehicle/truck/volvo.fh16_2012/engine/d13c540.sii"
+}
+}
+`
+
+// TestWriteUnitsCSV tests the columns and rows of a flat export in its different forms
+func TestWriteUnitsCSV(t *testing.T) {
+	units, err := ParseAllUnits(strings.NewReader(csvTestSii))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts []CSVOption
+		want string
+	}{
+		{
+			name: "joined",
+			want: `id,odometer,position_x,position_y,position_z,accessories,wheels_x,wheels_y,license_plate
+_nameless.1d4.8d36.7060,512345,1.5,2,3,_nameless.1d4.8d36.7080;_nameless.1d4.8d36.7090,,,
+vehicle.second,1200,,,,,1;3,2;4,"AB 123, CD"
+`,
+		},
+		{
+			name: "exploded tsv",
+			opts: []CSVOption{OptCSVComma('\t'), OptCSVExplode()},
+			want: "id\todometer\tposition_x\tposition_y\tposition_z\taccessories\twheels_x\twheels_y\tlicense_plate\n" +
+				"_nameless.1d4.8d36.7060\t512345\t1.5\t2\t3\t_nameless.1d4.8d36.7080\t\t\t\n" +
+				"_nameless.1d4.8d36.7060\t512345\t1.5\t2\t3\t_nameless.1d4.8d36.7090\t\t\t\n" +
+				"vehicle.second\t1200\t\t\t\t\t1\t2\tAB 123, CD\n" +
+				"vehicle.second\t1200\t\t\t\t\t3\t4\tAB 123, CD\n",
+		},
+		{
+			name: "array separator",
+			opts: []CSVOption{OptCSVArraySep("|")},
+			want: `id,odometer,position_x,position_y,position_z,accessories,wheels_x,wheels_y,license_plate
+_nameless.1d4.8d36.7060,512345,1.5,2,3,_nameless.1d4.8d36.7080|_nameless.1d4.8d36.7090,,,
+vehicle.second,1200,,,,,1|3,2|4,"AB 123, CD"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := WriteUnitsCSV(&sb, units, "vehicle", tt.opts...); err != nil {
+				t.Fatalf("WriteUnitsCSV() error = %v", err)
+			}
+			if sb.String() != tt.want {
+				t.Errorf("WriteUnitsCSV() =\n%s\nwant\n%s", sb.String(), tt.want)
+			}
+		})
+	}
+
+	var sb strings.Builder
+	if err := WriteUnitsCSV(&sb, units, "bank"); err != nil || sb.String() != "id\n" {
+		t.Errorf("WriteUnitsCSV() of a missing type = %q, %v, want only the header", sb.String(), err)
+	}
+
+	for _, opt := range []CSVOption{OptCSVComma('"'), OptCSVArraySep("")} {
+		if err := WriteUnitsCSV(&sb, units, "vehicle", opt); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("WriteUnitsCSV() error = %v, want %v", err, ErrInvalidOption)
+		}
+	}
+}
+
+// TestWriteUnitsCSVCollisions tests attributes named id and joined elements holding the separator
+func TestWriteUnitsCSVCollisions(t *testing.T) {
+	attrs := NewAttributes()
+	attrs.Set("id", NewIntAttribute(7))
+	attrs.Set("names", mustArray(t, NewStringAttribute("a;b"), NewStringAttribute(`"quoted"`), NewStringAttribute("c")))
+	units := []Unit{{Utype: "driver", ID: "driver.anna", Attrs: attrs}}
+
+	var sb strings.Builder
+	if err := WriteUnitsCSV(&sb, units, "driver"); err != nil {
+		t.Fatalf("WriteUnitsCSV() error = %v", err)
+	}
+	want := `id,attr_id,names
+driver.anna,7,"""a;b"";""""""quoted"""""";c"
+`
+	if sb.String() != want {
+		t.Errorf("WriteUnitsCSV() =\n%s\nwant\n%s", sb.String(), want)
+	}
+
+	// The names field splits back apart with a CSV reader using the separator
+	records, err := csv.NewReader(strings.NewReader(sb.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("csv ReadAll() error = %v", err)
+	}
+	elems := csv.NewReader(strings.NewReader(records[1][2]))
+	elems.Comma = ';'
+	names, err := elems.Read()
+	if err != nil || !reflect.DeepEqual(names, []string{"a;b", `"quoted"`, "c"}) {
+		t.Errorf("split names = %q, %v", names, err)
+	}
+}
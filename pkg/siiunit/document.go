@@ -0,0 +1,304 @@
+package siiunit
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrUnitNotFound = errors.New("unit not found")
+	ErrDuplicateID  = errors.New("duplicate unit id")
+)
+
+// RefFixup selects what Document.Delete does with pointers to the deleted unit
+type RefFixup int
+
+const (
+	// RefKeep leaves pointers to the deleted unit dangling
+	RefKeep RefFixup = iota
+	// RefNull replaces every pointer to the deleted unit with null
+	RefNull
+	// RefDrop removes array elements pointing to the deleted unit and replaces single pointers with null
+	RefDrop
+)
+
+// Document is an editable set of units, e.g. a parsed save. Units keep their order
+// and can be looked up by ID.
+type Document struct {
+	units    []Unit
+	index    map[string]int // Unit ID to position in units
+	nameless *NamelessAllocator
+
+	refs *RefIndex // Built on the first call to References
+
+	undo [][]Change // Committed transactions, newest last
+	redo [][]Change // Undone transactions, newest last
+}
+
+// NewDocument creates a document from parsed units. The units share their
+// attributes with the document, so edits through either are visible in both.
+func NewDocument(units []Unit) (*Document, error) {
+	d := &Document{
+		units:    make([]Unit, 0, len(units)),
+		index:    make(map[string]int, len(units)),
+		nameless: NewNamelessAllocator(nil),
+	}
+
+	for _, unit := range units {
+		if err := d.Add(unit); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// Units returns the units in document order
+func (d *Document) Units() []Unit {
+	return slices.Clone(d.units)
+}
+
+// Len returns the number of units
+func (d *Document) Len() int {
+	return len(d.units)
+}
+
+// Unit returns the unit with the given ID
+func (d *Document) Unit(id string) (Unit, bool) {
+	i, ok := d.index[id]
+	if !ok {
+		return Unit{}, false
+	}
+	return d.units[i], true
+}
+
+// Resolve is a UnitResolver for the document
+func (d *Document) Resolve(id string) (Unit, bool) {
+	return d.Unit(id)
+}
+
+// Add appends a unit to the end of the document
+func (d *Document) Add(unit Unit) error {
+	return d.insert(len(d.units), unit)
+}
+
+// insert adds unit at position i
+func (d *Document) insert(i int, unit Unit) error {
+	if _, exists := d.index[unit.ID]; exists {
+		return fmt.Errorf("%w: %s", ErrDuplicateID, unit.ID)
+	}
+	if unit.Attrs == nil {
+		unit.Attrs = newAttributes()
+	}
+
+	d.units = slices.Insert(d.units, i, unit)
+	d.reindex(i)
+	d.nameless.reserveUnit(unit)
+
+	if d.refs != nil {
+		d.watch(unit)
+		d.refs.addUnit(unit)
+	}
+	return nil
+}
+
+// References returns every attribute pointing at the unit with the given ID, including
+// array elements and pointers left dangling by Delete with RefKeep. The reference index
+// is built on the first call and then kept up to date as units and their attributes
+// are edited. A unit should only belong to one document while it is tracked.
+func (d *Document) References(id string) []RefLocation {
+	if d.refs == nil {
+		d.trackReferences()
+	}
+	return d.refs.To(id)
+}
+
+// trackReferences builds the reference index and watches every unit for changes
+func (d *Document) trackReferences() {
+	d.refs = NewRefIndex(d.units)
+	for _, unit := range d.units {
+		d.watch(unit)
+	}
+}
+
+// watch keeps the reference index up to date with the attributes of unit
+func (d *Document) watch(unit Unit) {
+	id, attrs := unit.ID, unit.Attrs
+	attrs.changed = func(key string) {
+		if d.refs != nil {
+			d.refs.update(id, attrs, key)
+		}
+	}
+}
+
+// reindex updates the positions of the units from i on
+func (d *Document) reindex(from int) {
+	for i := from; i < len(d.units); i++ {
+		d.index[d.units[i].ID] = i
+	}
+}
+
+// Create adds a new empty unit with a fresh nameless ID
+func (d *Document) Create(utype string) (Unit, error) {
+	unit := Unit{
+		Utype: utype,
+		ID:    d.nameless.Next(),
+		Attrs: newAttributes(),
+	}
+
+	if err := d.Add(unit); err != nil {
+		return Unit{}, err
+	}
+	return unit, nil
+}
+
+// Clone copies the unit with the given ID under newID and inserts the copy right after
+// the original. An empty newID gives the copy a fresh nameless ID. Pointers inside the
+// copy are left as they are, so a cloned vehicle shares its accessories until they
+// are cloned and swapped in as well.
+func (d *Document) Clone(id, newID string) (Unit, error) {
+	i, ok := d.index[id]
+	if !ok {
+		return Unit{}, fmt.Errorf("%w: %s", ErrUnitNotFound, id)
+	}
+
+	if newID == "" {
+		newID = d.nameless.Next()
+	}
+
+	clone := d.units[i].Clone(newID)
+	if err := d.insert(i+1, clone); err != nil {
+		return Unit{}, err
+	}
+	return clone, nil
+}
+
+// Delete removes the unit with the given ID and fixes up pointers to it
+func (d *Document) Delete(id string, fixup RefFixup) error {
+	i, ok := d.index[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnitNotFound, id)
+	}
+
+	unit := d.units[i]
+	d.units = slices.Delete(d.units, i, i+1)
+	delete(d.index, id)
+	d.reindex(i)
+
+	if d.refs != nil {
+		unit.Attrs.changed = nil
+		d.refs.removeUnit(unit)
+	}
+
+	if fixup == RefKeep {
+		return nil
+	}
+
+	d.rewritePointers(func(ptr string) (string, bool) {
+		if ptr != id {
+			return ptr, true
+		}
+		return "null", fixup == RefNull
+	})
+	return nil
+}
+
+// rewritePointers calls fn with every token attribute and token array element of every
+// unit. fn returns the new value, or false to remove an array element. Single
+// attributes are never removed, they are set to the returned value instead.
+func (d *Document) rewritePointers(fn func(ptr string) (string, bool)) {
+	for _, unit := range d.units {
+		for key, attr := range unit.Attrs.attrs {
+			if rewriteAttribute(attr, fn) {
+				unit.Attrs.notify(key)
+			}
+		}
+	}
+}
+
+// rewriteAttribute rewrites the tokens of attr with fn and reports if any changed
+func rewriteAttribute(attr *Attribute, fn func(ptr string) (string, bool)) bool {
+	if attr.Atype == AttributeTypeString && !attr.quoted {
+		old := attr.stringVal
+		attr.stringVal, _ = fn(old)
+		return attr.stringVal != old
+	}
+
+	if attr.Atype != AttributeTypeArray || attr.arrayElemType != AttributeTypeString {
+		return false
+	}
+
+	changed := false
+	kept := attr.arrayVals[:0]
+	for _, elem := range attr.arrayVals {
+		if !elem.quoted {
+			old := elem.stringVal
+			var keep bool
+			if elem.stringVal, keep = fn(old); !keep {
+				changed = true
+				continue
+			}
+			changed = changed || elem.stringVal != old
+		}
+		kept = append(kept, elem)
+	}
+	attr.arrayVals = kept
+	return changed
+}
+
+// RenumberNameless gives the nameless units new consecutive IDs in document order
+// and rewrites every pointer to match. Nameless pointers to units outside the
+// document are renumbered as well, after the units. It returns the old to new ID mapping.
+func (d *Document) RenumberNameless() map[string]string {
+	// The watchers know the units by their old IDs, so rebuild the index afterwards
+	if d.refs != nil {
+		d.refs = nil
+		defer d.trackReferences()
+	}
+
+	first := uint64(namelessBase)
+	found := false
+	for id := range d.index {
+		if n, ok := parseNameless(id); ok && (!found || n < first) {
+			first, found = n, true
+		}
+	}
+
+	mapping := make(map[string]string)
+	next := first &^ (namelessStep - 1)
+	assign := func(id string) {
+		if _, done := mapping[id]; done || !IsNameless(id) {
+			return
+		}
+		mapping[id] = formatNameless(next)
+		next += namelessStep
+	}
+
+	for _, unit := range d.units {
+		assign(unit.ID)
+	}
+	for _, unit := range d.units {
+		for _, attr := range unit.Attrs.All() {
+			forEachToken(&attr, assign)
+		}
+	}
+
+	for i := range d.units {
+		if id, ok := mapping[d.units[i].ID]; ok {
+			d.units[i].ID = id
+		}
+	}
+	d.rewritePointers(func(ptr string) (string, bool) {
+		if id, ok := mapping[ptr]; ok {
+			return id, true
+		}
+		return ptr, true
+	})
+
+	clear(d.index)
+	d.reindex(0)
+	d.nameless = NewNamelessAllocator(d.units)
+
+	return mapping
+}
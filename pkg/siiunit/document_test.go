@@ -0,0 +1,240 @@
+package siiunit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func parseTestDocument(t *testing.T) *Document {
+	t.Helper()
+
+	doc, err := NewDocument(parseTestSave(t))
+	if err != nil {
+		t.Fatalf("NewDocument() error = %v", err)
+	}
+	return doc
+}
+
+// TestNamelessFormat tests nameless IDs survive a format and parse round trip
+func TestNamelessFormat(t *testing.T) {
+	tests := []struct {
+		n  uint64
+		id string
+	}{
+		{n: 0x1, id: "_nameless.1"},
+		{n: 0xffff, id: "_nameless.ffff"},
+		{n: 0x10000, id: "_nameless.1.0000"},
+		{n: 0x1d48d367060, id: "_nameless.1d4.8d36.7060"},
+		{n: 0x2cdf1a85a70, id: "_nameless.2cd.f1a8.5a70"},
+	}
+
+	for _, tt := range tests {
+		if got := formatNameless(tt.n); got != tt.id {
+			t.Errorf("formatNameless(%x) = %v, want %v", tt.n, got, tt.id)
+		}
+		if got, ok := parseNameless(tt.id); !ok || got != tt.n {
+			t.Errorf("parseNameless(%v) = %x, %v, want %x", tt.id, got, ok, tt.n)
+		}
+	}
+
+	for _, id := range []string{"company.volatile.scania_fac.paris", "_nameless.", "_nameless.1d4.8d3", "_nameless.xyz", "_nameless.1.0000.0000.0000.0000"} {
+		if _, ok := parseNameless(id); ok {
+			t.Errorf("parseNameless(%v) should fail", id)
+		}
+	}
+}
+
+// TestDocumentClone duplicates a truck together with its accessories
+func TestDocumentClone(t *testing.T) {
+	doc := parseTestDocument(t)
+
+	truck, err := doc.Clone("_nameless.1d4.8d36.7060", "")
+	if err != nil {
+		t.Fatalf("Clone() error = %v", err)
+	}
+	if truck.ID != "_nameless.1d4.8d36.70a0" {
+		t.Errorf("Clone() ID = %v, want _nameless.1d4.8d36.70a0", truck.ID)
+	}
+
+	accessories, _ := GetAs[[]string](truck.Attrs, "accessories")
+	for i, id := range accessories {
+		acc, err := doc.Clone(id, "")
+		if err != nil {
+			t.Fatalf("Clone(%s) error = %v", id, err)
+		}
+		truck.Attrs.RemoveAt("accessories", i)
+		truck.Attrs.InsertAt("accessories", i, NewTokenAttribute(acc.ID))
+	}
+
+	// The original truck is untouched and the copy sits right after it
+	original, _ := doc.Unit("_nameless.1d4.8d36.7060")
+	if got, _ := GetAs[[]string](original.Attrs, "accessories"); !reflect.DeepEqual(got, accessories) {
+		t.Errorf("original accessories = %v, want %v", got, accessories)
+	}
+
+	wantAccessories := []string{"_nameless.1d4.8d36.70b0", "_nameless.1d4.8d36.70c0"}
+	if got, _ := GetAs[[]string](truck.Attrs, "accessories"); !reflect.DeepEqual(got, wantAccessories) {
+		t.Errorf("clone accessories = %v, want %v", got, wantAccessories)
+	}
+
+	ids := unitIDs(doc.Units())
+	if ids[7] != truck.ID {
+		t.Errorf("clone at %v, want right after the original", ids)
+	}
+
+	if _, err := doc.Clone("_nameless.1d4.8d36.7060", "_nameless.1d4.8d36.7080"); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("Clone() onto existing ID error = %v, want %v", err, ErrDuplicateID)
+	}
+	if _, err := doc.Clone("missing", ""); !errors.Is(err, ErrUnitNotFound) {
+		t.Errorf("Clone(missing) error = %v, want %v", err, ErrUnitNotFound)
+	}
+}
+
+// TestDocumentCreate tests new units get unused nameless IDs
+func TestDocumentCreate(t *testing.T) {
+	doc := parseTestDocument(t)
+
+	first, err := doc.Create("vehicle_accessory")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	second, _ := doc.Create("vehicle_accessory")
+
+	if first.ID != "_nameless.1d4.8d36.70a0" || second.ID != "_nameless.1d4.8d36.70b0" {
+		t.Errorf("Create() IDs = %v, %v", first.ID, second.ID)
+	}
+	if doc.Len() != 11 || first.Attrs.Len() != 0 {
+		t.Errorf("Create() gave %d units, %d attributes", doc.Len(), first.Attrs.Len())
+	}
+}
+
+// TestDocumentDelete tests every pointer fixup mode
+func TestDocumentDelete(t *testing.T) {
+	tests := []struct {
+		fixup     RefFixup
+		wantTruck string
+		wantAcc   []string
+	}{
+		{fixup: RefKeep, wantTruck: "_nameless.1d4.8d36.7060", wantAcc: []string{"_nameless.1d4.8d36.7080", "_nameless.1d4.8d36.7090"}},
+		{fixup: RefNull, wantTruck: "null", wantAcc: []string{"null", "_nameless.1d4.8d36.7090"}},
+		{fixup: RefDrop, wantTruck: "null", wantAcc: []string{"_nameless.1d4.8d36.7090"}},
+	}
+
+	for _, tt := range tests {
+		doc := parseTestDocument(t)
+
+		if err := doc.Delete("_nameless.1d4.8d36.7080", tt.fixup); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		truck, _ := doc.Unit("_nameless.1d4.8d36.7060")
+		if got, _ := GetAs[[]string](truck.Attrs, "accessories"); !reflect.DeepEqual(got, tt.wantAcc) {
+			t.Errorf("accessories = %v, want %v", got, tt.wantAcc)
+		}
+
+		if err := doc.Delete("_nameless.1d4.8d36.7060", tt.fixup); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		player, _ := doc.Unit("_nameless.1d4.8d36.7050")
+		if got, _ := GetAs[string](player.Attrs, "assigned_truck"); got != tt.wantTruck {
+			t.Errorf("assigned_truck = %v, want %v", got, tt.wantTruck)
+		}
+
+		if doc.Len() != 7 {
+			t.Errorf("Delete() left %d units, want 7", doc.Len())
+		}
+		if _, ok := doc.Unit("_nameless.1d4.8d36.7060"); ok {
+			t.Error("Unit() still finds the deleted unit")
+		}
+		if unit, ok := doc.Unit("_nameless.1d4.8d36.7090"); !ok || unit.Utype != "vehicle_accessory" {
+			t.Errorf("Unit() after Delete = %v, %v", unit, ok)
+		}
+	}
+
+	doc := parseTestDocument(t)
+	if err := doc.Delete("missing", RefNull); !errors.Is(err, ErrUnitNotFound) {
+		t.Errorf("Delete(missing) error = %v, want %v", err, ErrUnitNotFound)
+	}
+}
+
+// TestNewDocumentDuplicate tests duplicate IDs are rejected
+func TestNewDocumentDuplicate(t *testing.T) {
+	units := parseTestSave(t)
+	if _, err := NewDocument(append(units, units[1])); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("NewDocument() error = %v, want %v", err, ErrDuplicateID)
+	}
+}
+
+// TestNamelessAllocator tests allocated IDs never collide with seen units or pointers
+func TestNamelessAllocator(t *testing.T) {
+	units := parseTestSave(t)
+	units[5].Attrs.Set("job_offer", NewTokenAttribute("_nameless.1d4.8d36.7100"))
+
+	na := NewNamelessAllocator(units)
+	if got := na.Next(); got != "_nameless.1d4.8d36.7110" {
+		t.Errorf("Next() = %v, want _nameless.1d4.8d36.7110", got)
+	}
+
+	if na.Reserve("_nameless.1d4.8d36.7060") {
+		t.Error("Reserve() of a unit ID should fail")
+	}
+	if na.Reserve("company.volatile.scania_fac.paris") {
+		t.Error("Reserve() of a named ID should fail")
+	}
+	if !na.Reserve("_nameless.1d4.8d36.7125") {
+		t.Error("Reserve() of a free ID should succeed")
+	}
+	if got := na.Next(); got != "_nameless.1d4.8d36.7130" {
+		t.Errorf("Next() after Reserve = %v, want _nameless.1d4.8d36.7130", got)
+	}
+
+	if got := NewNamelessAllocator(nil).Next(); got != "_nameless.100.0000.0000" {
+		t.Errorf("Next() without seed = %v, want _nameless.100.0000.0000", got)
+	}
+}
+
+// TestRenumberNameless tests renumbering compacts IDs and keeps every pointer intact
+func TestRenumberNameless(t *testing.T) {
+	doc := parseTestDocument(t)
+	doc.Delete("_nameless.1d4.8d36.7040", RefKeep)
+	doc.Clone("_nameless.1d4.8d36.7080", "_nameless.2cd.f1a8.5a70")
+
+	mapping := doc.RenumberNameless()
+
+	wantIDs := []string{
+		"_nameless.1d4.8d36.7030",
+		"_nameless.1d4.8d36.7040",
+		"company.volatile.scania_fac.paris",
+		"_nameless.1d4.8d36.7050",
+		"company.volatile.volvo_dlr.berlin",
+		"_nameless.1d4.8d36.7060",
+		"_nameless.1d4.8d36.7070",
+		"_nameless.1d4.8d36.7080",
+		"_nameless.1d4.8d36.7090",
+	}
+	if got := unitIDs(doc.Units()); !reflect.DeepEqual(got, wantIDs) {
+		t.Errorf("RenumberNameless() IDs = %v, want %v", got, wantIDs)
+	}
+
+	// The dangling bank pointer gets a number after the units
+	economy, _ := doc.Unit("_nameless.1d4.8d36.7030")
+	if got, _ := GetAs[string](economy.Attrs, "bank"); got != "_nameless.1d4.8d36.70a0" {
+		t.Errorf("bank = %v, want _nameless.1d4.8d36.70a0", got)
+	}
+	if got, _ := GetAs[string](economy.Attrs, "player"); got != "_nameless.1d4.8d36.7040" {
+		t.Errorf("player = %v, want _nameless.1d4.8d36.7040", got)
+	}
+
+	truck, ok := doc.Unit(mapping["_nameless.1d4.8d36.7060"])
+	if !ok {
+		t.Fatalf("Unit() can't find the renumbered truck")
+	}
+	wantAcc := []string{"_nameless.1d4.8d36.7070", "_nameless.1d4.8d36.7090"}
+	if got, _ := GetAs[[]string](truck.Attrs, "accessories"); !reflect.DeepEqual(got, wantAcc) {
+		t.Errorf("accessories = %v, want %v", got, wantAcc)
+	}
+
+	if unit, _ := doc.Create("bank"); unit.ID != "_nameless.1d4.8d36.70b0" {
+		t.Errorf("Create() after renumber = %v, want _nameless.1d4.8d36.70b0", unit.ID)
+	}
+}
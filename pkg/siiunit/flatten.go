@@ -0,0 +1,91 @@
+package siiunit
+
+import "slices"
+
+// vectorSuffixes name the columns of split tuples
+var vectorSuffixes = [4]string{"_x", "_y", "_z", "_w"}
+
+// placementSuffixes name the columns of a split placement, position then rotation
+var placementSuffixes = [7]string{"_x", "_y", "_z", "_rw", "_rx", "_ry", "_rz"}
+
+// flattenAttribute calls fn with the column name and plain Go value of every part of a
+// non array attribute. Tuples are split into key_x, key_y, key_z and key_w columns and
+// placements into a position and a key_rw to key_rz rotation. Values are string,
+// float64, int64 or bool.
+func flattenAttribute(key string, attr *Attribute, fn func(column string, value any)) {
+	floats := func(fs []float64, suffixes []string) {
+		for i, f := range fs {
+			fn(key+suffixes[i], f)
+		}
+	}
+	ints := func(is []int64) {
+		for i, n := range is {
+			fn(key+vectorSuffixes[i], n)
+		}
+	}
+
+	switch attr.Atype {
+	case AttributeTypeString:
+		fn(key, attr.stringVal)
+	case AttributeTypeFloat:
+		fn(key, attr.floatVal)
+	case AttributeTypeFloat2:
+		floats(attr.float2Vals[:], vectorSuffixes[:])
+	case AttributeTypeFloat3:
+		floats(attr.float3Vals[:], vectorSuffixes[:])
+	case AttributeTypeFloat4:
+		floats(attr.float4Vals[:], vectorSuffixes[:])
+	case AttributeTypePlacement:
+		floats(append(attr.placementPos[:], attr.placementRot[:]...), placementSuffixes[:])
+	case AttributeTypeInt:
+		fn(key, attr.intVal)
+	case AttributeTypeInt2:
+		ints(attr.int2Vals[:])
+	case AttributeTypeInt3:
+		ints(attr.int3Vals[:])
+	case AttributeTypeInt4:
+		ints(attr.int4Vals[:])
+	case AttributeTypeBool:
+		fn(key, attr.boolVal)
+	}
+}
+
+// arrayKeys returns the keys holding an array in any of the units. Empty arrays are
+// written as key: 0 and read back as ints, so such ints are empty arrays for these keys.
+func arrayKeys(units []Unit) map[string]bool {
+	keys := make(map[string]bool)
+	for _, unit := range units {
+		for key, attr := range unit.Attrs.All() {
+			if attr.Atype == AttributeTypeArray {
+				keys[key] = true
+			}
+		}
+	}
+	return keys
+}
+
+// attributeKeys returns the keys of all attributes of the units
+func attributeKeys(units []Unit) map[string]bool {
+	keys := make(map[string]bool)
+	for _, unit := range units {
+		for _, key := range unit.Attrs.Keys() {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// attrColumn returns the column of a flattened attribute in a table with the given key
+// columns. Columns named like a key column get an attr_ prefix, once more for every
+// attribute in keys already using that name.
+func attrColumn(column string, keys map[string]bool, keyColumns ...string) string {
+	if !slices.Contains(keyColumns, column) {
+		return column
+	}
+
+	column = "attr_" + column
+	for keys[column] {
+		column = "attr_" + column
+	}
+	return column
+}
@@ -0,0 +1,83 @@
+package siiunit
+
+import (
+	"fmt"
+)
+
+// ExtractFragment returns a copy of the unit with the given ID and every unit it owns,
+// owners first, e.g. a truck with its accessories. Pointers to units outside the
+// fragment are kept and left dangling. Write the fragment with WriteUnits to get a
+// standalone SII document. opts configure ownership like for BuildOwnershipTree, named
+// units are only part of the fragment if owned through OptOwnedKeys, e.g.
+//
+//	fragment, err := ExtractFragment(units, "garage.berlin", OptOwnedKeys("garage.drivers"))
+func ExtractFragment(units []Unit, id string, opts ...OwnershipOption) ([]Unit, error) {
+	tree, err := BuildOwnershipTree(units, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	node, ok := tree.Node(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnitNotFound, id)
+	}
+
+	fragment := node.Subtree()
+	for i, unit := range fragment {
+		fragment[i] = unit.Clone(unit.ID)
+	}
+	return fragment, nil
+}
+
+// ImportFragment appends copies of the fragment units to the document. Nameless units
+// get fresh IDs and pointers between fragment units are rewritten to match. Pointers to
+// named units out of the fragment are kept as they are, pointers to nameless units
+// out of the fragment are set to null as their IDs mean nothing in the document.
+// Named units have to be new to the document. It returns the old to new ID mapping
+// of the nameless units.
+func (d *Document) ImportFragment(fragment []Unit) (map[string]string, error) {
+	seen := make(map[string]bool, len(fragment))
+	for _, unit := range fragment {
+		if seen[unit.ID] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateID, unit.ID)
+		}
+		seen[unit.ID] = true
+
+		if _, exists := d.index[unit.ID]; exists && !IsNameless(unit.ID) {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateID, unit.ID)
+		}
+	}
+
+	mapping := make(map[string]string)
+	for _, unit := range fragment {
+		if IsNameless(unit.ID) {
+			mapping[unit.ID] = d.nameless.Next()
+		}
+	}
+
+	for _, unit := range fragment {
+		id := unit.ID
+		if newID, ok := mapping[id]; ok {
+			id = newID
+		}
+
+		clone := unit.Clone(id)
+		for _, attr := range clone.Attrs.attrs {
+			rewriteAttribute(attr, func(ptr string) (string, bool) {
+				if newID, ok := mapping[ptr]; ok {
+					return newID, true
+				}
+				if IsNameless(ptr) {
+					return "null", true
+				}
+				return ptr, true
+			})
+		}
+
+		if err := d.Add(clone); err != nil {
+			return nil, err
+		}
+	}
+
+	return mapping, nil
+}
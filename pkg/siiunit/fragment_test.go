@@ -0,0 +1,175 @@
+package siiunit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFragmentRoundTrip exports a truck with its accessories and imports it into another save
+func TestFragmentRoundTrip(t *testing.T) {
+	units := parseTestSave(t)
+
+	fragment, err := ExtractFragment(units, "_nameless.1d4.8d36.7060")
+	if err != nil {
+		t.Fatalf("ExtractFragment() error = %v", err)
+	}
+	want := []string{"_nameless.1d4.8d36.7060", "_nameless.1d4.8d36.7080", "_nameless.1d4.8d36.7090"}
+	if got := unitIDs(fragment); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExtractFragment() = %v, want %v", got, want)
+	}
+
+	// The fragment is a copy
+	fragment[0].Attrs.Set("odometer", NewIntAttribute(0))
+	if odometer, _ := GetAs[int64](units[6].Attrs, "odometer"); odometer != 512345 {
+		t.Errorf("ExtractFragment() shares attributes, odometer = %d", odometer)
+	}
+
+	// Through text, like a fragment file would
+	var sb strings.Builder
+	if err := WriteUnits(&sb, fragment); err != nil {
+		t.Fatalf("WriteUnits() error = %v", err)
+	}
+	parsed, err := ParseAllUnits(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	// Import into the same save, so every nameless ID is taken already
+	doc := parseTestDocument(t)
+	mapping, err := doc.ImportFragment(parsed)
+	if err != nil {
+		t.Fatalf("ImportFragment() error = %v", err)
+	}
+
+	wantMapping := map[string]string{
+		"_nameless.1d4.8d36.7060": "_nameless.1d4.8d36.70a0",
+		"_nameless.1d4.8d36.7080": "_nameless.1d4.8d36.70b0",
+		"_nameless.1d4.8d36.7090": "_nameless.1d4.8d36.70c0",
+	}
+	if !reflect.DeepEqual(mapping, wantMapping) {
+		t.Errorf("ImportFragment() mapping = %v, want %v", mapping, wantMapping)
+	}
+
+	truck, ok := doc.Unit("_nameless.1d4.8d36.70a0")
+	if !ok || doc.Len() != 12 {
+		t.Fatalf("ImportFragment() left %d units", doc.Len())
+	}
+	accessories, _ := GetAs[[]string](truck.Attrs, "accessories")
+	if !reflect.DeepEqual(accessories, []string{"_nameless.1d4.8d36.70b0", "_nameless.1d4.8d36.70c0"}) {
+		t.Errorf("accessories = %v, want the imported ones", accessories)
+	}
+
+	// The original truck is untouched
+	original, _ := doc.Unit("_nameless.1d4.8d36.7060")
+	if accessories, _ := GetAs[[]string](original.Attrs, "accessories"); accessories[0] != "_nameless.1d4.8d36.7080" {
+		t.Errorf("original accessories = %v", accessories)
+	}
+}
+
+// TestFragmentExternalRefs tests pointers out of the fragment are left dangling
+func TestFragmentExternalRefs(t *testing.T) {
+	units := parseTestSave(t)
+
+	fragment, err := ExtractFragment(units, "company.volatile.scania_fac.paris")
+	if err != nil {
+		t.Fatalf("ExtractFragment() error = %v", err)
+	}
+	if got := unitIDs(fragment); !reflect.DeepEqual(got, []string{"company.volatile.scania_fac.paris", "_nameless.1d4.8d36.7070"}) {
+		t.Fatalf("ExtractFragment() = %v", got)
+	}
+	if data, _ := GetAs[string](fragment[0].Attrs, "permanent_data"); data != "company.permanent.scania_fac" {
+		t.Errorf("permanent_data = %v, want the external pointer kept", data)
+	}
+
+	// A named unit can't be imported twice
+	doc := parseTestDocument(t)
+	if _, err := doc.ImportFragment(fragment); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("ImportFragment() error = %v, want %v", err, ErrDuplicateID)
+	}
+	if doc.Len() != 9 {
+		t.Errorf("failed ImportFragment() added units, %d left", doc.Len())
+	}
+
+	if _, err := ExtractFragment(units, "missing"); !errors.Is(err, ErrUnitNotFound) {
+		t.Errorf("ExtractFragment(missing) error = %v, want %v", err, ErrUnitNotFound)
+	}
+}
+
+const garageSii = `SiiNunit
+{
+garage : garage.berlin {
+ drivers: 2
+ drivers[0]: driver.anna
+ drivers[1]: driver.ben
+ trucks: 1
+ trucks[0]: _nameless.1d4.8d36.7060
+ city: city.berlin
+}
+driver : driver.anna {
+ garage: garage.berlin
+ profit_log: _nameless.1d4.8d36.7100
+}
+driver : driver.ben {
+ garage: garage.berlin
+}
+profit_log : _nameless.1d4.8d36.7100 {
+ stats: 0
+}
+vehicle : _nameless.1d4.8d36.7060 {
+ odometer: 100
+}
+}
+`
+
+// TestFragmentOwnedNamedUnits exports a garage with its named drivers
+func TestFragmentOwnedNamedUnits(t *testing.T) {
+	units, err := ParseAllUnits(strings.NewReader(garageSii))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	fragment, _ := ExtractFragment(units, "garage.berlin")
+	if got := unitIDs(fragment); !reflect.DeepEqual(got, []string{"garage.berlin", "_nameless.1d4.8d36.7060"}) {
+		t.Errorf("ExtractFragment() = %v, want the drivers left out", got)
+	}
+
+	fragment, err = ExtractFragment(units, "garage.berlin", OptOwnedKeys("garage.drivers"))
+	if err != nil {
+		t.Fatalf("ExtractFragment() error = %v", err)
+	}
+	want := []string{"garage.berlin", "driver.anna", "_nameless.1d4.8d36.7100", "driver.ben", "_nameless.1d4.8d36.7060"}
+	if got := unitIDs(fragment); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFragment() = %v, want %v", got, want)
+	}
+
+	if _, err := ExtractFragment(units, "garage.berlin", OptOwnedKeys("")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("ExtractFragment() error = %v, want %v", err, ErrInvalidOption)
+	}
+}
+
+// TestImportFragmentDanglingNameless tests nameless pointers out of the fragment are nulled
+func TestImportFragmentDanglingNameless(t *testing.T) {
+	units, err := ParseAllUnits(strings.NewReader(garageSii))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	// The profit log is left out, its ID is the one of the player in the test save
+	fragment := []Unit{units[1].Clone("driver.anna")}
+	fragment[0].Attrs.Set("profit_log", NewTokenAttribute("_nameless.1d4.8d36.7050"))
+
+	doc := parseTestDocument(t)
+	if _, err := doc.ImportFragment(fragment); err != nil {
+		t.Fatalf("ImportFragment() error = %v", err)
+	}
+
+	driver, _ := doc.Unit("driver.anna")
+	if log, _ := GetAs[string](driver.Attrs, "profit_log"); log != "null" {
+		t.Errorf("profit_log = %s, want null", log)
+	}
+	if garage, _ := GetAs[string](driver.Attrs, "garage"); garage != "garage.berlin" {
+		t.Errorf("garage = %s, want the named pointer kept", garage)
+	}
+}
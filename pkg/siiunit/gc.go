@@ -0,0 +1,181 @@
+package siiunit
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+// GCReport lists the units a garbage collection found unreachable
+type GCReport struct {
+	Total       int    // Units checked
+	Unreachable []Unit // In document order
+	DryRun      bool   // The units were only listed, not removed
+}
+
+// Write writes a human readable summary of the report
+func (r *GCReport) Write(w io.Writer) error {
+	action := "removed"
+	if r.DryRun {
+		action = "would be removed"
+	}
+
+	if _, err := fmt.Fprintf(w, "%d of %d units unreachable, %s\n", len(r.Unreachable), r.Total, action); err != nil {
+		return err
+	}
+	for _, unit := range r.Unreachable {
+		if _, err := fmt.Fprintf(w, "  %s : %s\n", unit.Utype, unit.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultGCRootTypes are the global units of a save, the economy and the controllers
+// and accounts hanging off it
+var defaultGCRootTypes = []string{
+	"economy",
+	"bank",
+	"player",
+	"economy_event_queue",
+	"mail_ctrl",
+	"oversize_offer_ctrl",
+	"police_ctrl",
+	"delivery_log",
+	"ferry_log",
+}
+
+type GCOption func(*gcOptions) error
+
+type gcOptions struct {
+	rootTypes map[string]bool
+	rootIDs   map[string]bool
+}
+
+// OptGCRootTypes makes every unit of the given types a root, replacing the default
+// economy, bank, player and controller types
+func OptGCRootTypes(types ...string) GCOption {
+	return func(gco *gcOptions) error {
+		if len(types) == 0 {
+			return fmt.Errorf("OptGCRootTypes: need at least 1 unit type")
+		}
+		clear(gco.rootTypes)
+		for _, t := range types {
+			gco.rootTypes[t] = true
+		}
+		return nil
+	}
+}
+
+// OptGCRootIDs adds the units with the given IDs to the roots
+func OptGCRootIDs(ids ...string) GCOption {
+	return func(gco *gcOptions) error {
+		if len(ids) == 0 {
+			return fmt.Errorf("OptGCRootIDs: need at least 1 id")
+		}
+		for _, id := range ids {
+			gco.rootIDs[id] = true
+		}
+		return nil
+	}
+}
+
+// FindUnreachable returns the units that can't be reached by following pointers from
+// the root units, in document order. The roots are the units of the global save types
+// like economy, bank and player, or the first unit if there is none, unless set with
+// OptGCRootTypes and OptGCRootIDs.
+func FindUnreachable(units []Unit, opts ...GCOption) ([]Unit, error) {
+	options := &gcOptions{
+		rootTypes: make(map[string]bool, len(defaultGCRootTypes)),
+		rootIDs:   make(map[string]bool),
+	}
+	for _, t := range defaultGCRootTypes {
+		options.rootTypes[t] = true
+	}
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidOption, err)
+		}
+	}
+
+	byID := make(map[string]Unit, len(units))
+	var queue []Unit
+	for _, unit := range units {
+		byID[unit.ID] = unit
+		if options.rootTypes[unit.Utype] || options.rootIDs[unit.ID] {
+			queue = append(queue, unit)
+		}
+	}
+	if len(queue) == 0 && len(units) > 0 {
+		queue = append(queue, units[0])
+	}
+
+	reached := make(map[string]bool, len(units))
+	for _, unit := range queue {
+		reached[unit.ID] = true
+	}
+
+	for len(queue) > 0 {
+		unit := queue[0]
+		queue = queue[1:]
+
+		for _, attr := range unit.Attrs.All() {
+			forEachPointer(&attr, func(ptr string, _ int) {
+				target, ok := byID[ptr]
+				if !ok || reached[ptr] {
+					return
+				}
+				reached[ptr] = true
+				queue = append(queue, target)
+			})
+		}
+	}
+
+	var unreachable []Unit
+	for _, unit := range units {
+		if !reached[unit.ID] {
+			unreachable = append(unreachable, unit)
+		}
+	}
+	return unreachable, nil
+}
+
+// CollectGarbage removes the units that can't be reached from the roots, see
+// FindUnreachable. With dryRun the units are only reported.
+func (d *Document) CollectGarbage(dryRun bool, opts ...GCOption) (*GCReport, error) {
+	unreachable, err := FindUnreachable(d.units, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{
+		Total:       len(d.units),
+		Unreachable: unreachable,
+		DryRun:      dryRun,
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	garbage := make(map[string]bool, len(unreachable))
+	for _, unit := range unreachable {
+		garbage[unit.ID] = true
+	}
+
+	// Only unreachable units can point to unreachable units, so there is nothing to fix up
+	d.units = slices.DeleteFunc(d.units, func(unit Unit) bool {
+		if !garbage[unit.ID] {
+			return false
+		}
+
+		delete(d.index, unit.ID)
+		if d.refs != nil {
+			unit.Attrs.changed = nil
+			d.refs.removeUnit(unit)
+		}
+		return true
+	})
+	d.reindex(0)
+
+	return report, nil
+}
@@ -0,0 +1,95 @@
+package siiunit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFindUnreachable tests the reachability pass over the pointer graph
+func TestFindUnreachable(t *testing.T) {
+	units := parseTestSave(t)
+
+	unreachable, err := FindUnreachable(units)
+	if err != nil {
+		t.Fatalf("FindUnreachable() error = %v", err)
+	}
+	if len(unreachable) != 0 {
+		t.Errorf("FindUnreachable() = %v, want none", unitIDs(unreachable))
+	}
+
+	// Dropping the truck pointer loses the truck and its accessories
+	units[2].Attrs.Set("assigned_truck", NewNullAttribute())
+	unreachable, _ = FindUnreachable(units)
+	want := []string{"_nameless.1d4.8d36.7060", "_nameless.1d4.8d36.7080", "_nameless.1d4.8d36.7090"}
+	if got := unitIDs(unreachable); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindUnreachable() = %v, want %v", got, want)
+	}
+
+	// The player is a root of its own even when the economy doesn't point to it
+	units[0].Attrs.Set("player", NewNullAttribute())
+	unreachable, _ = FindUnreachable(units)
+	if got := unitIDs(unreachable); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindUnreachable() without the economy's player = %v, want %v", got, want)
+	}
+
+	unreachable, _ = FindUnreachable(units, OptGCRootIDs("_nameless.1d4.8d36.7060"))
+	if len(unreachable) != 0 {
+		t.Errorf("FindUnreachable() with extra root = %v, want none", unitIDs(unreachable))
+	}
+
+	unreachable, _ = FindUnreachable(units, OptGCRootTypes("company"))
+	want = []string{"_nameless.1d4.8d36.7030", "_nameless.1d4.8d36.7040", "_nameless.1d4.8d36.7050", "_nameless.1d4.8d36.7060", "_nameless.1d4.8d36.7080", "_nameless.1d4.8d36.7090"}
+	if got := unitIDs(unreachable); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindUnreachable() from companies = %v, want %v", got, want)
+	}
+
+	if _, err := FindUnreachable(units, OptGCRootTypes()); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("FindUnreachable() error = %v, want %v", err, ErrInvalidOption)
+	}
+}
+
+// TestCollectGarbage tests the dry run report and the removal
+func TestCollectGarbage(t *testing.T) {
+	doc := parseTestDocument(t)
+	doc.Create("vehicle_accessory")
+	player, _ := doc.Unit("_nameless.1d4.8d36.7050")
+	player.Attrs.Delete("assigned_truck")
+
+	report, err := doc.CollectGarbage(true)
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %v", err)
+	}
+	if doc.Len() != 10 {
+		t.Errorf("dry run removed units, %d left", doc.Len())
+	}
+
+	var sb strings.Builder
+	report.Write(&sb)
+	want := `4 of 10 units unreachable, would be removed
+  vehicle : _nameless.1d4.8d36.7060
+  vehicle_accessory : _nameless.1d4.8d36.7080
+  vehicle_accessory : _nameless.1d4.8d36.7090
+  vehicle_accessory : _nameless.1d4.8d36.70a0
+`
+	if sb.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", sb.String(), want)
+	}
+
+	report, _ = doc.CollectGarbage(false)
+	if len(report.Unreachable) != 4 || doc.Len() != 6 {
+		t.Errorf("CollectGarbage() removed %d units, %d left", len(report.Unreachable), doc.Len())
+	}
+	for _, id := range []string{"_nameless.1d4.8d36.7050", "company.volatile.volvo_dlr.berlin"} {
+		if unit, ok := doc.Unit(id); !ok || unit.ID != id {
+			t.Errorf("Unit(%s) after CollectGarbage() = %s, %v", id, unit.ID, ok)
+		}
+	}
+	if _, ok := doc.Unit("_nameless.1d4.8d36.7080"); ok {
+		t.Errorf("Unit() still finds a removed unit")
+	}
+	if report, _ := doc.CollectGarbage(false); len(report.Unreachable) != 0 {
+		t.Errorf("second CollectGarbage() = %v, want nothing", unitIDs(report.Unreachable))
+	}
+}
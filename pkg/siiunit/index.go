@@ -0,0 +1,184 @@
+package siiunit
+
+import (
+	"slices"
+	"sync"
+)
+
+// Index looks units up by ID, by type and by attribute value without scanning them all.
+// Attribute indexes are built the first time they are used, or upfront with IndexAttr.
+// An Index is safe for concurrent use. It holds the attribute values of the time the
+// attribute index was built, so rebuild it after editing indexed attributes.
+type Index struct {
+	mu     sync.RWMutex
+	units  []Unit
+	byID   map[string]int
+	byType map[string][]int
+	byAttr map[attrIndexKey]map[string][]int // Attribute value to unit positions
+}
+
+type attrIndexKey struct {
+	utype string
+	key   string
+}
+
+// NewIndex creates an index over units. The zero Index is an empty index ready to use.
+func NewIndex(units []Unit) *Index {
+	idx := &Index{}
+	idx.Add(units...)
+	return idx
+}
+
+// Add adds units to the index. If a unit ID is already indexed, lookups by ID keep
+// returning the first unit with it. Adding to a nil index does nothing.
+func (idx *Index) Add(units ...Unit) {
+	if idx == nil {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.byID == nil {
+		idx.byID = make(map[string]int, len(units))
+		idx.byType = make(map[string][]int)
+	}
+
+	for _, unit := range units {
+		i := len(idx.units)
+		idx.units = append(idx.units, unit)
+
+		if _, exists := idx.byID[unit.ID]; !exists {
+			idx.byID[unit.ID] = i
+		}
+		idx.byType[unit.Utype] = append(idx.byType[unit.Utype], i)
+
+		for key, values := range idx.byAttr {
+			if key.utype == unit.Utype {
+				indexAttr(values, unit, key.key, i)
+			}
+		}
+	}
+}
+
+// Len returns the number of indexed units
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.units)
+}
+
+// Unit returns the unit with the given ID
+func (idx *Index) Unit(id string) (Unit, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	i, ok := idx.byID[id]
+	if !ok {
+		return Unit{}, false
+	}
+	return idx.units[i], true
+}
+
+// Resolve is a UnitResolver for the index
+func (idx *Index) Resolve(id string) (Unit, bool) {
+	return idx.Unit(id)
+}
+
+// ByType returns the units of a type in the order they were added
+func (idx *Index) ByType(utype string) []Unit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.collect(idx.byType[utype])
+}
+
+// Types returns the indexed unit types sorted by name
+func (idx *Index) Types() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	types := make([]string, 0, len(idx.byType))
+	for utype := range idx.byType {
+		types = append(types, utype)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// IndexAttr builds the index on key for units of utype, if there isn't one yet
+func (idx *Index) IndexAttr(utype, key string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.attrIndex(utype, key)
+}
+
+// Lookup returns the units of utype whose attribute key has the given value, in
+// the order they were added. Values compare as text the way queries show them, and
+// units with an array attribute are found by any of its elements.
+func (idx *Index) Lookup(utype, key, value string) []Unit {
+	idx.mu.RLock()
+	values, ok := idx.byAttr[attrIndexKey{utype, key}]
+	if ok {
+		defer idx.mu.RUnlock()
+		return idx.collect(values[value])
+	}
+	idx.mu.RUnlock()
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.collect(idx.attrIndex(utype, key)[value])
+}
+
+// attrIndex returns the index on key for units of utype, building it if needed.
+// The caller has to hold the write lock.
+func (idx *Index) attrIndex(utype, key string) map[string][]int {
+	if idx.byAttr == nil {
+		idx.byAttr = make(map[attrIndexKey]map[string][]int)
+	}
+
+	k := attrIndexKey{utype, key}
+	if values, ok := idx.byAttr[k]; ok {
+		return values
+	}
+
+	values := make(map[string][]int)
+	for _, i := range idx.byType[utype] {
+		indexAttr(values, idx.units[i], key, i)
+	}
+
+	idx.byAttr[k] = values
+	return values
+}
+
+// indexAttr adds the unit at position i under the value of its attribute key
+func indexAttr(values map[string][]int, unit Unit, key string, i int) {
+	attr, ok := unit.Attrs.lookup(key)
+	if !ok {
+		return
+	}
+
+	if attr.Atype != AttributeTypeArray {
+		values[attr.text()] = append(values[attr.text()], i)
+		return
+	}
+
+	for j := range attr.arrayVals {
+		v := attr.arrayVals[j].text()
+		// Don't list a unit twice if an array holds the same value more than once
+		if positions := values[v]; len(positions) == 0 || positions[len(positions)-1] != i {
+			values[v] = append(positions, i)
+		}
+	}
+}
+
+func (idx *Index) collect(positions []int) []Unit {
+	if len(positions) == 0 {
+		return nil
+	}
+
+	units := make([]Unit, len(positions))
+	for j, i := range positions {
+		units[j] = idx.units[i]
+	}
+	return units
+}
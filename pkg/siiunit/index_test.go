@@ -0,0 +1,118 @@
+package siiunit
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestIndex tests lookups by ID, type and attribute value
+func TestIndex(t *testing.T) {
+	idx := &Index{}
+	units, err := ParseAllUnitsConcurrent(strings.NewReader(testSave), OptIndex(idx))
+	if err != nil {
+		t.Fatalf("ParseAllUnitsConcurrent() error = %v", err)
+	}
+	if idx.Len() != len(units) {
+		t.Errorf("Len() = %d, want %d", idx.Len(), len(units))
+	}
+
+	if unit, ok := idx.Unit("_nameless.1d4.8d36.7060"); !ok || unit.Utype != "vehicle" {
+		t.Errorf("Unit() = %v, %v", unit, ok)
+	}
+	if _, ok := idx.Unit("missing"); ok {
+		t.Error("Unit(missing) = true, want false")
+	}
+
+	if got := unitIDs(idx.ByType("company")); !reflect.DeepEqual(got, []string{"company.volatile.scania_fac.paris", "company.volatile.volvo_dlr.berlin"}) {
+		t.Errorf("ByType(company) = %v", got)
+	}
+	if got := idx.Types(); len(got) != 7 || got[0] != "bank" {
+		t.Errorf("Types() = %v", got)
+	}
+
+	tests := []struct {
+		utype, key, value string
+		want              []string
+	}{
+		{"company", "permanent_data", "company.permanent.volvo_dlr", []string{"company.volatile.volvo_dlr.berlin"}},
+		{"job_offer_data", "target", "volvo_dlr.berlin", []string{"_nameless.1d4.8d36.7070"}},
+		{"vehicle", "accessories", "_nameless.1d4.8d36.7090", []string{"_nameless.1d4.8d36.7060"}},
+		{"bank", "coinsurance_fixed", "1", []string{"_nameless.1d4.8d36.7040"}},
+		{"company", "permanent_data", "company.permanent.daf", nil},
+		{"vehicle", "odometer", "1", nil},
+	}
+	for _, tt := range tests {
+		got := idx.Lookup(tt.utype, tt.key, tt.value)
+		var ids []string
+		if got != nil {
+			ids = unitIDs(got)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Lookup(%s, %s, %s) = %v, want %v", tt.utype, tt.key, tt.value, ids, tt.want)
+		}
+	}
+
+	// Existing attribute indexes pick up units added later
+	idx.Add(Unit{Utype: "company", ID: "company.volatile.volvo_dlr.lyon", Attrs: units[5].Attrs})
+	if got := idx.Lookup("company", "permanent_data", "company.permanent.volvo_dlr"); len(got) != 2 {
+		t.Errorf("Lookup() after Add = %v, want 2 units", unitIDs(got))
+	}
+}
+
+// TestIndexConcurrent builds attribute indexes from several goroutines at once
+func TestIndexConcurrent(t *testing.T) {
+	units, err := ParseAllUnits(strings.NewReader(generateSave(500, 1)))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	idx := NewIndex(units)
+	want := len(idx.Lookup("job_offer_data", "target", "volvo_dlr.berlin"))
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			idx.Lookup("company", "job_offer", fmt.Sprint(i))
+			if got := len(idx.Lookup("job_offer_data", "target", "volvo_dlr.berlin")); got != want {
+				t.Errorf("Lookup() = %d units, want %d", got, want)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// BenchmarkIndexLookup compares an indexed lookup with a linear scan
+func BenchmarkIndexLookup(b *testing.B) {
+	units, err := ParseAllUnits(strings.NewReader(generateSave(20000, 1)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	id := units[len(units)/2].ID
+
+	b.Run("index", func(b *testing.B) {
+		idx := NewIndex(units)
+		for b.Loop() {
+			if _, ok := idx.Unit(id); !ok {
+				b.Fatal("unit not found")
+			}
+		}
+	})
+
+	b.Run("scan", func(b *testing.B) {
+		for b.Loop() {
+			found := false
+			for _, unit := range units {
+				if unit.ID == id {
+					found = true
+					break
+				}
+			}
+			if !found {
+				b.Fatal("unit not found")
+			}
+		}
+	})
+}
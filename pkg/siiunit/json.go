@@ -0,0 +1,406 @@
+package siiunit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// The JSON form keeps the type of every attribute next to its value:
+//
+//	{"type": "float3", "value": [1, 2, 3]}
+//	{"type": "string", "token": true, "value": "_nameless.1d4.8d36.7060"}
+//	{"type": "float", "hex": true, "value": 1}
+//	{"type": "placement", "value": {"pos": [1, 2, 3], "rot": [1, 0, 0, 0]}}
+//	{"type": "array", "elem": "string", "token": true, "value": ["a", "b"]}
+//
+// token marks unquoted strings and hex marks floats written in &xxxxxxxx form. Values
+// mixing both forms, like a placement with a decimal position and a hex rotation, list
+// the floats written in decimal in decimals, bit i for float i. Arrays hold plain
+// element values, their token and hex flags come from the first element.
+// JSON has no NaN or infinity, so such floats are the string "&xxxxxxxx" of their
+// float32 bits. The attributes of a unit are an object in SII order.
+
+type jsonAttribute struct {
+	Type     string          `json:"type"`
+	Elem     string          `json:"elem,omitempty"`
+	Token    bool            `json:"token,omitempty"`
+	Hex      bool            `json:"hex,omitempty"`
+	Decimals uint8           `json:"decimals,omitempty"`
+	Value    json.RawMessage `json:"value"`
+}
+
+type jsonPlacement struct {
+	Pos [3]jsonFloat `json:"pos"`
+	Rot [4]jsonFloat `json:"rot"`
+}
+
+// jsonFloat is a float that encodes NaN and infinities in &xxxxxxxx form
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return fmt.Appendf(nil, `"&%08x"`, math.Float32bits(float32(f))), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(s, "&") {
+			return fmt.Errorf("%w: float %q", ErrParsingFailed, s)
+		}
+		v, err := parseHexFloat(s)
+		*f = jsonFloat(v)
+		return err
+	}
+
+	var v float64
+	err := json.Unmarshal(data, &v)
+	*f = jsonFloat(v)
+	return err
+}
+
+// jsonFloats converts the floats of a tuple to their JSON form
+func jsonFloats(fs []float64) []jsonFloat {
+	js := make([]jsonFloat, len(fs))
+	for i, f := range fs {
+		js[i] = jsonFloat(f)
+	}
+	return js
+}
+
+// marshalJSON is json.Marshal without escaping &, < and >, so hex floats and tokens
+// stay readable in nested values
+func marshalJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+type jsonUnit struct {
+	Utype string      `json:"utype"`
+	ID    string      `json:"id"`
+	Attrs *Attributes `json:"attrs"`
+}
+
+type jsonDocument struct {
+	Units []Unit `json:"units"`
+}
+
+// WriteUnitsJSON writes units as typed JSON, see ReadUnitsJSON for the way back
+func WriteUnitsJSON(w io.Writer, units []Unit) error {
+	if units == nil {
+		units = []Unit{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(jsonDocument{Units: units})
+}
+
+// ReadUnitsJSON reads units written by WriteUnitsJSON
+func ReadUnitsJSON(r io.Reader) ([]Unit, error) {
+	var doc jsonDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.Units, nil
+}
+
+// MarshalJSON encodes the unit as an object with its type, ID and typed attributes
+func (u Unit) MarshalJSON() ([]byte, error) {
+	attrs := u.Attrs
+	if attrs == nil {
+		attrs = newAttributes()
+	}
+	return marshalJSON(jsonUnit{Utype: u.Utype, ID: u.ID, Attrs: attrs})
+}
+
+// UnmarshalJSON decodes a unit encoded by MarshalJSON
+func (u *Unit) UnmarshalJSON(data []byte) error {
+	ju := jsonUnit{Attrs: newAttributes()}
+	if err := json.Unmarshal(data, &ju); err != nil {
+		return err
+	}
+
+	*u = Unit{Utype: ju.Utype, ID: ju.ID, Attrs: ju.Attrs}
+	return nil
+}
+
+// MarshalJSON encodes the attributes as an object in SII order
+func (as *Attributes) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	i := 0
+	for key, attr := range as.All() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		i++
+
+		k, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := attr.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode attribute %s: %w", key, err)
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes attributes encoded by MarshalJSON, keeping their order
+func (as *Attributes) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('{') {
+		return errors.New("attributes must be a JSON object")
+	}
+
+	decoded := newAttributes()
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := tok.(string)
+
+		var attr Attribute
+		if err := dec.Decode(&attr); err != nil {
+			return fmt.Errorf("failed to decode attribute %s: %w", key, err)
+		}
+		decoded.set(key, &attr)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	as.attrs, as.keys = decoded.attrs, decoded.keys
+	return nil
+}
+
+// MarshalJSON encodes the attribute with its type, see WriteUnitsJSON
+func (a Attribute) MarshalJSON() ([]byte, error) {
+	ja := jsonAttribute{
+		Type:  attributeTypeNames[a.Atype],
+		Token: a.Atype == AttributeTypeString && !a.quoted,
+		Hex:   a.hexFloat,
+	}
+	if a.hexFloat {
+		ja.Decimals = a.decimals
+	}
+
+	var value any
+	if a.Atype == AttributeTypeArray {
+		ja.Elem = attributeTypeNames[a.arrayElemType]
+
+		values := make([]any, len(a.arrayVals))
+		for i := range a.arrayVals {
+			values[i] = a.arrayVals[i].jsonValue()
+		}
+		value = values
+
+		if len(a.arrayVals) > 0 {
+			first := &a.arrayVals[0]
+			ja.Token = first.Atype == AttributeTypeString && !first.quoted
+			ja.Hex = first.hexFloat
+			if first.hexFloat {
+				ja.Decimals = first.decimals
+			}
+		}
+	} else {
+		value = a.jsonValue()
+	}
+
+	raw, err := marshalJSON(value)
+	if err != nil {
+		return nil, err
+	}
+	ja.Value = raw
+
+	return marshalJSON(ja)
+}
+
+// UnmarshalJSON decodes an attribute encoded by MarshalJSON
+func (a *Attribute) UnmarshalJSON(data []byte) error {
+	var ja jsonAttribute
+	if err := json.Unmarshal(data, &ja); err != nil {
+		return err
+	}
+
+	atype, ok := attributeTypeByName(ja.Type)
+	if !ok {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidType, ja.Type)
+	}
+
+	if atype != AttributeTypeArray {
+		attr, err := attributeFromJSON(atype, ja.Value, ja.Token, ja.Hex)
+		if err != nil {
+			return err
+		}
+		if attr.hexFloat {
+			attr.decimals = ja.Decimals
+		}
+		*a = attr
+		return nil
+	}
+
+	elemType, ok := attributeTypeByName(ja.Elem)
+	if !ok || elemType == AttributeTypeArray {
+		return fmt.Errorf("%w: unknown array element type %q", ErrInvalidType, ja.Elem)
+	}
+
+	var values []json.RawMessage
+	if err := json.Unmarshal(ja.Value, &values); err != nil {
+		return err
+	}
+
+	arr := Attribute{}
+	arr.makeArray(len(values))
+	arr.arrayElemType = elemType
+	for i, v := range values {
+		elem, err := attributeFromJSON(elemType, v, ja.Token, ja.Hex)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		if elem.hexFloat {
+			elem.decimals = ja.Decimals
+		}
+		arr.arrayVals = append(arr.arrayVals, elem)
+	}
+
+	*a = arr
+	return nil
+}
+
+// jsonValue returns the plain value of a non array attribute
+func (a *Attribute) jsonValue() any {
+	switch a.Atype {
+	case AttributeTypeString:
+		return a.stringVal
+	case AttributeTypeFloat:
+		return jsonFloat(a.floatVal)
+	case AttributeTypeFloat2:
+		return jsonFloats(a.float2Vals[:])
+	case AttributeTypeFloat3:
+		return jsonFloats(a.float3Vals[:])
+	case AttributeTypeFloat4:
+		return jsonFloats(a.float4Vals[:])
+	case AttributeTypePlacement:
+		p := jsonPlacement{}
+		copy(p.Pos[:], jsonFloats(a.placementPos[:]))
+		copy(p.Rot[:], jsonFloats(a.placementRot[:]))
+		return p
+	case AttributeTypeInt:
+		return a.intVal
+	case AttributeTypeInt2:
+		return a.int2Vals
+	case AttributeTypeInt3:
+		return a.int3Vals
+	case AttributeTypeInt4:
+		return a.int4Vals
+	case AttributeTypeBool:
+		return a.boolVal
+	default:
+		return nil
+	}
+}
+
+// attributeFromJSON decodes the plain value of a non array attribute
+func attributeFromJSON(atype AttributeType, value json.RawMessage, token, hex bool) (Attribute, error) {
+	a := Attribute{Atype: atype}
+
+	var target any
+	switch atype {
+	case AttributeTypeString:
+		target = &a.stringVal
+		a.quoted = !token
+	case AttributeTypeFloat:
+		target = (*jsonFloat)(&a.floatVal)
+		a.hexFloat = hex
+	case AttributeTypeFloat2, AttributeTypeFloat3, AttributeTypeFloat4:
+		a.hexFloat = hex
+		err := a.floatsFromJSON(value)
+		return a, err
+	case AttributeTypePlacement:
+		var p jsonPlacement
+		if err := json.Unmarshal(value, &p); err != nil {
+			return a, err
+		}
+		for i, f := range p.Pos {
+			a.placementPos[i] = float64(f)
+		}
+		for i, f := range p.Rot {
+			a.placementRot[i] = float64(f)
+		}
+		a.hexFloat = hex
+		return a, nil
+	case AttributeTypeInt:
+		target = &a.intVal
+	case AttributeTypeInt2:
+		target = &a.int2Vals
+	case AttributeTypeInt3:
+		target = &a.int3Vals
+	case AttributeTypeInt4:
+		target = &a.int4Vals
+	case AttributeTypeBool:
+		target = &a.boolVal
+	default:
+		return a, fmt.Errorf("%w: %s", ErrInvalidType, attributeTypeNames[atype])
+	}
+
+	if err := json.Unmarshal(value, target); err != nil {
+		return a, fmt.Errorf("%s value: %w", attributeTypeNames[atype], err)
+	}
+	return a, nil
+}
+
+// floatsFromJSON decodes the value of a float tuple attribute
+func (a *Attribute) floatsFromJSON(value json.RawMessage) error {
+	var fs []float64
+	switch a.Atype {
+	case AttributeTypeFloat2:
+		fs = a.float2Vals[:]
+	case AttributeTypeFloat3:
+		fs = a.float3Vals[:]
+	case AttributeTypeFloat4:
+		fs = a.float4Vals[:]
+	}
+
+	var js []jsonFloat
+	if err := json.Unmarshal(value, &js); err != nil {
+		return fmt.Errorf("%s value: %w", attributeTypeNames[a.Atype], err)
+	}
+	if len(js) != len(fs) {
+		return fmt.Errorf("%w: %s value has %d elements", ErrParsingFailed, attributeTypeNames[a.Atype], len(js))
+	}
+	for i, f := range js {
+		fs[i] = float64(f)
+	}
+	return nil
+}
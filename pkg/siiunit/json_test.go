@@ -0,0 +1,130 @@
+package siiunit
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestUnitsJSONRoundTrip tests units survive JSON with types, quoting and hex floats intact
+func TestUnitsJSONRoundTrip(t *testing.T) {
+	units := parseTestSave(t)
+	units[1].Attrs.Set("limits", NewInt2Attribute([2]int64{10, 20}))
+	units[1].Attrs.Set("shares", mustArray(t, NewHexFloatAttribute(0.5), NewHexFloatAttribute(1)))
+	units[1].Attrs.Set("names", mustArray(t, NewStringAttribute("a b"), NewStringAttribute("c")))
+	units[1].Attrs.Set("empty", mustArray(t))
+	units[1].Attrs.Set("broken", NewBoolAttribute(false))
+	units[1].Attrs.Set("wear", NewHexFloatAttribute(math.NaN()))
+
+	var sb strings.Builder
+	if err := WriteUnitsJSON(&sb, units); err != nil {
+		t.Fatalf("WriteUnitsJSON() error = %v", err)
+	}
+	if !strings.Contains(sb.String(), `"value": "&7fc00000"`) {
+		t.Errorf("WriteUnitsJSON() doesn't write NaN as &7fc00000:\n%s", sb.String())
+	}
+
+	decoded, err := ReadUnitsJSON(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("ReadUnitsJSON() error = %v", err)
+	}
+
+	if got, want := writeTestUnits(t, decoded), writeTestUnits(t, units); got != want {
+		t.Errorf("JSON round trip =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestAttributeJSON tests the typed JSON form of single attributes
+func TestAttributeJSON(t *testing.T) {
+	tests := []struct {
+		attr Attribute
+		want string
+	}{
+		{attr: NewStringAttribute("Volvo FH16"), want: `{"type":"string","value":"Volvo FH16"}`},
+		{attr: NewTokenAttribute("cargo.wood"), want: `{"type":"string","token":true,"value":"cargo.wood"}`},
+		{attr: NewHexFloatAttribute(1), want: `{"type":"float","hex":true,"value":1}`},
+		{attr: NewHexFloatAttribute(math.Inf(1)), want: `{"type":"float","hex":true,"value":"\u00267f800000"}`},
+		{attr: NewHexFloatAttribute(float64(math.Float32frombits(0x7fc00001))), want: `{"type":"float","hex":true,"value":"\u00267fc00001"}`},
+		{attr: NewFloat3Attribute([3]float64{1, 2, 3}), want: `{"type":"float3","value":[1,2,3]}`},
+		{attr: NewFloat2Attribute([2]float64{math.Inf(-1), 2}), want: `{"type":"float2","value":["\u0026ff800000",2]}`},
+		{attr: NewPlacementAttribute([3]float64{1, math.NaN(), 3}, [4]float64{1, 0, 0, 0}), want: `{"type":"placement","value":{"pos":[1,"\u00267fc00000",3],"rot":[1,0,0,0]}}`},
+		{attr: mustArray(t, NewHexFloatAttribute(math.Inf(1)), NewHexFloatAttribute(1)), want: `{"type":"array","elem":"float","hex":true,"value":["\u00267f800000",1]}`},
+		{attr: NewPlacementAttribute([3]float64{1, 2, 3}, [4]float64{1, 0, 0, 0}), want: `{"type":"placement","value":{"pos":[1,2,3],"rot":[1,0,0,0]}}`},
+		{attr: NewInt4Attribute([4]int64{1, 2, 3, 4}), want: `{"type":"int4","value":[1,2,3,4]}`},
+		{attr: NewBoolAttribute(true), want: `{"type":"bool","value":true}`},
+		{attr: mustArray(t, NewTokenAttribute("a"), NewTokenAttribute("b")), want: `{"type":"array","elem":"string","token":true,"value":["a","b"]}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.attr)
+		if err != nil {
+			t.Errorf("Marshal() error = %v", err)
+			continue
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal() = %s, want %s", data, tt.want)
+		}
+
+		var decoded Attribute
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", data, err)
+			continue
+		}
+		if decoded.siiValue() != tt.attr.siiValue() || decoded.TypeName() != tt.attr.TypeName() {
+			t.Errorf("Unmarshal(%s) = %v %v, want %v", data, decoded.TypeName(), decoded.siiValue(), tt.attr.siiValue())
+		}
+	}
+
+	invalid := []string{
+		`{"type":"float5","value":1}`,
+		`{"type":"int","value":"x"}`,
+		`{"type":"float","value":"inf"}`,
+		`{"type":"float3","value":[1,2]}`,
+		`{"type":"array","elem":"array","value":[]}`,
+		`{"type":"array","elem":"int","value":[1,"x"]}`,
+	}
+	for _, data := range invalid {
+		var attr Attribute
+		if err := json.Unmarshal([]byte(data), &attr); err == nil {
+			t.Errorf("Unmarshal(%s) should fail", data)
+		}
+	}
+
+	var attr Attribute
+	if err := json.Unmarshal([]byte(`{"type":"vector","value":1}`), &attr); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrInvalidType)
+	}
+}
+
+// TestUnitJSONOrder tests attributes keep their order through JSON
+func TestUnitJSONOrder(t *testing.T) {
+	data := `{"utype":"bank","id":"_nameless.1d4.8d36.7040","attrs":{"z":{"type":"int","value":1},"a":{"type":"int","value":2},"m":{"type":"int","value":3}}}`
+
+	var unit Unit
+	if err := json.Unmarshal([]byte(data), &unit); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if keys := strings.Join(unit.Attrs.Keys(), ","); keys != "z,a,m" {
+		t.Errorf("Keys() = %v, want z,a,m", keys)
+	}
+
+	out, err := json.Marshal(unit)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(out) != data {
+		t.Errorf("Marshal() = %s, want %s", out, data)
+	}
+}
+
+func mustArray(t *testing.T, elems ...Attribute) Attribute {
+	t.Helper()
+
+	arr, err := NewArrayAttribute(elems...)
+	if err != nil {
+		t.Fatalf("NewArrayAttribute() error = %v", err)
+	}
+	return arr
+}
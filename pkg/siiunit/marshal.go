@@ -0,0 +1,446 @@
+package siiunit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+var (
+	ErrInvalidSource = errors.New("marshal source must be a struct or a non-nil pointer to a struct")
+	ErrMissingID     = errors.New("marshal source has no unit ID")
+	ErrMissingUtype  = errors.New("marshal source has no unit type")
+)
+
+// MarshalTypeError describes a Go value that can't be stored as an attribute
+type MarshalTypeError struct {
+	Key  string       // Attribute key, array elements get an [index] suffix
+	Type reflect.Type // Go type of the source
+	Hint string       // Type hint from the struct tag, if any
+}
+
+func (e *MarshalTypeError) Error() string {
+	if e.Hint != "" {
+		return fmt.Sprintf("cannot marshal Go value of type %s into %s attribute %s", e.Type, e.Hint, e.Key)
+	}
+	return fmt.Sprintf("cannot marshal Go value of type %s into attribute %s", e.Type, e.Key)
+}
+
+// Unwrap makes errors.Is(err, ErrInvalidType) work for type mismatches
+func (e *MarshalTypeError) Unwrap() error {
+	return ErrInvalidType
+}
+
+// Marshal builds a Unit from a struct tagged the same way Unmarshal expects (see structFields).
+//
+// Go values map to attributes like this:
+//
+//	string                        -> quoted string, or a token with the token/ptr hint
+//	int, uint kinds               -> int
+//	float32, float64              -> float, in &xxxxxxxx form with the hexfloat option
+//	bool                          -> bool
+//	[2-4] numeric arrays          -> float2/3/4 or int2/3/4 depending on the element kind
+//	structs with 2-4 numeric fields -> the same as arrays
+//	Placement                     -> placement
+//	slices                        -> array
+//	Attribute                     -> as is
+//	pointers and structs of units -> pointer to the unit ID, nil is null
+//
+// A type hint such as `sii:"pos,float3"` converts between int and float attributes
+// of the same shape.
+func Marshal(v any) (Unit, error) {
+	e := &encoder{}
+	return e.marshal(v)
+}
+
+// MarshalAll is Marshal but also returns every unit referenced through struct fields,
+// the marshalled unit first and then the referenced ones depth first.
+func MarshalAll(v any) ([]Unit, error) {
+	e := &encoder{collect: true, seen: make(map[string]bool)}
+
+	unit, err := e.marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Unit{unit}, e.units...), nil
+}
+
+type encoder struct {
+	collect bool // Collect referenced units
+	units   []Unit
+	seen    map[string]bool
+}
+
+func (e *encoder) marshal(v any) (Unit, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return Unit{}, ErrInvalidSource
+	}
+
+	return e.encodeUnit(rv)
+}
+
+func (e *encoder) encodeUnit(rv reflect.Value) (Unit, error) {
+	unit := Unit{Attrs: newAttributes()}
+
+	// Mark the unit before its fields so references back to it don't encode it again
+	if e.collect {
+		if id := unitStructID(rv); id != "" {
+			e.seen[id] = true
+		}
+	}
+
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldByIndexSafe(rv, f.index)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case f.id:
+			unit.ID = fv.String()
+		case f.utype:
+			unit.Utype = fv.String()
+			if unit.Utype == "" {
+				unit.Utype = f.name
+			}
+		default:
+			attr, err := e.encodeValue(fv, f.name, f.hint, f.hexFloat)
+			if err != nil {
+				return Unit{}, err
+			}
+			unit.Attrs.set(f.name, &attr)
+		}
+	}
+
+	if unit.ID == "" {
+		return Unit{}, fmt.Errorf("%w: %s", ErrMissingID, rv.Type())
+	}
+	if unit.Utype == "" {
+		return Unit{}, fmt.Errorf("%w: %s", ErrMissingUtype, rv.Type())
+	}
+
+	return unit, nil
+}
+
+// encodeValue turns rv into an attribute, key is only used for error messages
+func (e *encoder) encodeValue(rv reflect.Value, key, hint string, hexFloat bool) (Attribute, error) {
+	typeErr := &MarshalTypeError{Key: key, Type: rv.Type(), Hint: hint}
+
+	if rv.Type() == attributeGoType {
+		return rv.Interface().(Attribute), nil
+	}
+
+	var attr Attribute
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return Attribute{Atype: AttributeTypeString, stringVal: "null"}, nil
+		}
+		return e.encodeValue(rv.Elem(), key, hint, hexFloat)
+
+	case reflect.String:
+		attr = Attribute{Atype: AttributeTypeString, stringVal: rv.String(), quoted: hint != "token"}
+		if hint == "token" {
+			return attr, nil
+		}
+
+	case reflect.Bool:
+		attr = Attribute{Atype: AttributeTypeBool, boolVal: rv.Bool()}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		attr = Attribute{Atype: AttributeTypeInt, intVal: rv.Int()}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return Attribute{}, typeErr
+		}
+		attr = Attribute{Atype: AttributeTypeInt, intVal: int64(rv.Uint())}
+
+	case reflect.Float32, reflect.Float64:
+		attr = Attribute{Atype: AttributeTypeFloat, floatVal: rv.Float()}
+
+	case reflect.Array:
+		var ok bool
+		if attr, ok = vectorFromValues(rv.Len(), rv.Index); !ok {
+			return e.encodeArray(rv, key, hint, hexFloat)
+		}
+
+	case reflect.Slice:
+		return e.encodeArray(rv, key, hint, hexFloat)
+
+	case reflect.Struct:
+		if rv.Type() == placementGoType {
+			p := rv.Interface().(Placement)
+			attr = Attribute{Atype: AttributeTypePlacement, placementPos: p.Pos, placementRot: p.Rot}
+			break
+		}
+
+		if isUnitStruct(rv.Type()) {
+			return e.encodeReference(rv)
+		}
+
+		var ok bool
+		if attr, ok = vectorFromValues(rv.NumField(), rv.Field); !ok {
+			return Attribute{}, typeErr
+		}
+
+	default:
+		return Attribute{}, typeErr
+	}
+
+	if hint != "" && !convertAttribute(&attr, hint) {
+		return Attribute{}, typeErr
+	}
+
+	attr.hexFloat = hexFloat
+	return attr, nil
+}
+
+// encodeArray turns a slice or non-vector array into an array attribute
+func (e *encoder) encodeArray(rv reflect.Value, key, hint string, hexFloat bool) (Attribute, error) {
+	attr := Attribute{}
+	attr.makeArray(rv.Len())
+
+	// Empty arrays still get the element type of the Go slice
+	zero, err := e.encodeValue(reflect.Zero(rv.Type().Elem()), key, hint, hexFloat)
+	if err == nil {
+		attr.arrayElemType = zero.Atype
+	}
+
+	for i := range rv.Len() {
+		elemKey := fmt.Sprintf("%s[%d]", key, i)
+
+		elem, err := e.encodeValue(rv.Index(i), elemKey, hint, hexFloat)
+		if err != nil {
+			return Attribute{}, err
+		}
+
+		if elem.Atype == AttributeTypeArray {
+			return Attribute{}, &MarshalTypeError{Key: elemKey, Type: rv.Index(i).Type(), Hint: hint}
+		}
+
+		if i == 0 {
+			attr.arrayElemType = elem.Atype
+		} else if elem.Atype != attr.arrayElemType {
+			return Attribute{}, fmt.Errorf("cannot append %s to array of %s at %s", attributeTypeNames[elem.Atype], attributeTypeNames[attr.arrayElemType], elemKey)
+		}
+
+		attr.arrayVals = append(attr.arrayVals, elem)
+	}
+
+	return attr, nil
+}
+
+// encodeReference turns a unit struct into a pointer to its ID, collecting the unit for MarshalAll
+func (e *encoder) encodeReference(rv reflect.Value) (Attribute, error) {
+	id := unitStructID(rv)
+	if id == "" {
+		return Attribute{}, fmt.Errorf("%w: %s", ErrMissingID, rv.Type())
+	}
+
+	if e.collect && !e.seen[id] {
+		unit, err := e.encodeUnit(rv)
+		if err != nil {
+			return Attribute{}, err
+		}
+		e.units = append(e.units, unit)
+	}
+
+	return Attribute{Atype: AttributeTypeString, stringVal: id}, nil
+}
+
+// unitStructID returns the value of the unit ID field of a unit struct
+func unitStructID(rv reflect.Value) string {
+	for _, f := range structFields(rv.Type()) {
+		if f.id {
+			if fv, ok := fieldByIndexSafe(rv, f.index); ok {
+				return fv.String()
+			}
+		}
+	}
+	return ""
+}
+
+// isUnitStruct reports if t has a field tagged as unit ID
+func isUnitStruct(t reflect.Type) bool {
+	for _, f := range structFields(t) {
+		if f.id {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	floatVectorTypes = [5]AttributeType{2: AttributeTypeFloat2, 3: AttributeTypeFloat3, 4: AttributeTypeFloat4}
+	intVectorTypes   = [5]AttributeType{2: AttributeTypeInt2, 3: AttributeTypeInt3, 4: AttributeTypeInt4}
+)
+
+// vectorFromValues builds a float or int vector from n numeric values, all of the same kind family
+func vectorFromValues(n int, at func(int) reflect.Value) (Attribute, bool) {
+	if n < 2 || n > 4 {
+		return Attribute{}, false
+	}
+
+	var floats [4]float64
+	var ints [4]int64
+	isFloat := false
+
+	for i := range n {
+		v := at(i)
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if i > 0 && !isFloat {
+				return Attribute{}, false
+			}
+			isFloat = true
+			floats[i] = v.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if isFloat {
+				return Attribute{}, false
+			}
+			ints[i] = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if isFloat || v.Uint() > math.MaxInt64 {
+				return Attribute{}, false
+			}
+			ints[i] = int64(v.Uint())
+		default:
+			return Attribute{}, false
+		}
+	}
+
+	if isFloat {
+		attr := Attribute{Atype: floatVectorTypes[n]}
+		attr.setFloatVector(floats)
+		return attr, true
+	}
+
+	attr := Attribute{Atype: intVectorTypes[n]}
+	attr.setIntVector(ints)
+	return attr, true
+}
+
+// convertAttribute converts attr in place to the attribute type named by hint.
+// Only conversions between ints and floats of the same shape are allowed.
+func convertAttribute(attr *Attribute, hint string) bool {
+	target, ok := attributeTypeByName(hint)
+	if !ok {
+		target = AttributeType(-1)
+	}
+
+	switch {
+	case target == attr.Atype:
+		return true
+
+	case target == AttributeTypeFloat && attr.Atype == AttributeTypeInt:
+		*attr = Attribute{Atype: AttributeTypeFloat, floatVal: float64(attr.intVal)}
+		return true
+
+	case target == AttributeTypeInt && attr.Atype == AttributeTypeFloat:
+		if attr.floatVal != math.Trunc(attr.floatVal) {
+			return false
+		}
+		*attr = Attribute{Atype: AttributeTypeInt, intVal: int64(attr.floatVal)}
+		return true
+	}
+
+	// Vectors of the same length
+	for n := 2; n <= 4; n++ {
+		switch {
+		case target == floatVectorTypes[n] && attr.Atype == intVectorTypes[n]:
+			var floats [4]float64
+			for i, v := range attr.intVector() {
+				floats[i] = float64(v)
+			}
+			*attr = Attribute{Atype: target}
+			attr.setFloatVector(floats)
+			return true
+
+		case target == intVectorTypes[n] && attr.Atype == floatVectorTypes[n]:
+			var ints [4]int64
+			for i, v := range attr.floatVector() {
+				if v != math.Trunc(v) {
+					return false
+				}
+				ints[i] = int64(v)
+			}
+			*attr = Attribute{Atype: target}
+			attr.setIntVector(ints)
+			return true
+		}
+	}
+
+	return false
+}
+
+// setFloatVector stores the first 2, 3 or 4 values depending on the float vector type
+func (a *Attribute) setFloatVector(v [4]float64) {
+	switch a.Atype {
+	case AttributeTypeFloat2:
+		a.float2Vals = [2]float64{v[0], v[1]}
+	case AttributeTypeFloat3:
+		a.float3Vals = [3]float64{v[0], v[1], v[2]}
+	case AttributeTypeFloat4:
+		a.float4Vals = v
+	}
+}
+
+// setIntVector stores the first 2, 3 or 4 values depending on the int vector type
+func (a *Attribute) setIntVector(v [4]int64) {
+	switch a.Atype {
+	case AttributeTypeInt2:
+		a.int2Vals = [2]int64{v[0], v[1]}
+	case AttributeTypeInt3:
+		a.int3Vals = [3]int64{v[0], v[1], v[2]}
+	case AttributeTypeInt4:
+		a.int4Vals = v
+	}
+}
+
+// floatVector returns the components of a float vector attribute
+func (a *Attribute) floatVector() []float64 {
+	switch a.Atype {
+	case AttributeTypeFloat2:
+		return a.float2Vals[:]
+	case AttributeTypeFloat3:
+		return a.float3Vals[:]
+	case AttributeTypeFloat4:
+		return a.float4Vals[:]
+	}
+	return nil
+}
+
+// intVector returns the components of an int vector attribute
+func (a *Attribute) intVector() []int64 {
+	switch a.Atype {
+	case AttributeTypeInt2:
+		return a.int2Vals[:]
+	case AttributeTypeInt3:
+		return a.int3Vals[:]
+	case AttributeTypeInt4:
+		return a.int4Vals[:]
+	}
+	return nil
+}
+
+// fieldByIndexSafe is reflect.Value.FieldByIndex but reports false on nil embedded pointers
+func fieldByIndexSafe(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,280 @@
+package siiunit
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testTruck struct {
+	ID          string           `sii:",id"`
+	Utype       string           `sii:"vehicle,utype"`
+	Odometer    uint32           `sii:"odometer"`
+	Fuel        float32          `sii:"fuel_relative,hexfloat"`
+	Wear        [2]float64       `sii:"wear"`
+	Size        [3]float32       `sii:"size"`
+	Pos         testVec3         `sii:"pos"`
+	Placement   Placement        `sii:"placement"`
+	Brand       string           `sii:"brand,token"`
+	Name        string           `sii:"name"`
+	Broken      bool             `sii:"broken"`
+	Accessories []*testAccessory `sii:"accessories"`
+	Driver      *testAccessory   `sii:"driver"`
+	Tags        []string         `sii:"tags,token"`
+}
+
+func newTestTruck() testTruck {
+	return testTruck{
+		ID:        "_nameless.1d4.8d36.7060",
+		Odometer:  512345,
+		Fuel:      0.5,
+		Wear:      [2]float64{0.25, 1},
+		Size:      [3]float32{1, 2, 3},
+		Pos:       testVec3{1.5, -2.5, 3},
+		Placement: Placement{Pos: [3]float64{1, 2, 3}, Rot: [4]float64{1, 0, 0, 0}},
+		Brand:     "volvo",
+		Name:      "My truck",
+		Broken:    true,
+		Accessories: []*testAccessory{
+			{ID: "_nameless.1d4.8d36.7080", Utype: "vehicle_accessory", DataPath: "/def/vehicle/truck/volvo.fh16_2012/engine/d13c540.sii"},
+			{ID: "_nameless.1d4.8d36.7090", Utype: "vehicle_accessory", DataPath: "/def/vehicle/truck/volvo.fh16_2012/chassis/4x2.sii"},
+		},
+		Tags: []string{},
+	}
+}
+
+// TestMarshal tests building units from tagged structs
+func TestMarshal(t *testing.T) {
+	unit, err := Marshal(newTestTruck())
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	if unit.Utype != "vehicle" || unit.ID != "_nameless.1d4.8d36.7060" {
+		t.Errorf("Marshal() header = %s : %s", unit.Utype, unit.ID)
+	}
+
+	want := map[string]string{
+		"odometer":      "512345",
+		"fuel_relative": "&3f000000",
+		"wear":          "(0.25, 1.0)",
+		"size":          "(1.0, 2.0, 3.0)",
+		"pos":           "(1.5, -2.5, 3.0)",
+		"placement":     "(1.0, 2.0, 3.0) (1.0; 0.0, 0.0, 0.0)",
+		"brand":         "volvo",
+		"name":          `"My truck"`,
+		"broken":        "true",
+		"driver":        "null",
+		"accessories":   "2",
+		"tags":          "0",
+	}
+
+	for key, val := range want {
+		attr, ok := unit.Attrs.attrs[key]
+		if !ok {
+			t.Errorf("Marshal() is missing %s", key)
+			continue
+		}
+		if got := attr.siiValue(); got != val {
+			t.Errorf("Marshal() %s = %s, want %s", key, got, val)
+		}
+	}
+
+	if len(unit.Attrs.keys) != 12 || unit.Attrs.keys[0] != "odometer" || unit.Attrs.keys[11] != "tags" {
+		t.Errorf("Marshal() keys = %v, want struct field order", unit.Attrs.keys)
+	}
+}
+
+// TestMarshalHints tests converting Go values with type hints
+func TestMarshalHints(t *testing.T) {
+	v := struct {
+		ID     string  `sii:",id"`
+		Utype  string  `sii:"thing,utype"`
+		Size   [3]int  `sii:"size,float3"`
+		Count  float64 `sii:"count,int"`
+		Scale  int     `sii:"scale,float"`
+		Ratios []int   `sii:"ratios,float"`
+	}{ID: "thing.one", Size: [3]int{1, 2, 3}, Count: 4, Scale: 2, Ratios: []int{1, 2}}
+
+	unit, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := map[string]AttributeType{
+		"size":   AttributeTypeFloat3,
+		"count":  AttributeTypeInt,
+		"scale":  AttributeTypeFloat,
+		"ratios": AttributeTypeArray,
+	}
+	for key, atype := range want {
+		if got := unit.Attrs.attrs[key].Atype; got != atype {
+			t.Errorf("Marshal() %s = %s, want %s", key, attributeTypeNames[got], attributeTypeNames[atype])
+		}
+	}
+
+	if elem := unit.Attrs.attrs["ratios"].arrayElemType; elem != AttributeTypeFloat {
+		t.Errorf("Marshal() ratios element type = %s, want float", attributeTypeNames[elem])
+	}
+}
+
+// TestMarshalRoundTrip tests Marshal, WriteUnits, ParseAllUnits and Unmarshal together
+func TestMarshalRoundTrip(t *testing.T) {
+	truck := newTestTruck()
+
+	units, err := MarshalAll(truck)
+	if err != nil {
+		t.Fatalf("MarshalAll() error = %v", err)
+	}
+
+	if got := unitIDs(units); !reflect.DeepEqual(got, []string{truck.ID, truck.Accessories[0].ID, truck.Accessories[1].ID}) {
+		t.Fatalf("MarshalAll() ids = %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteUnits(&buf, units); err != nil {
+		t.Fatalf("WriteUnits() error = %v", err)
+	}
+
+	parsed, err := ParseAllUnits(&buf)
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	var got testTruck
+	if err := UnmarshalResolve(parsed[0], &got, testResolver(parsed)); err != nil {
+		t.Fatalf("UnmarshalResolve() error = %v", err)
+	}
+
+	// The default unit type is filled in and the empty slice comes back nil
+	truck.Utype = "vehicle"
+	truck.Tags = nil
+
+	if !reflect.DeepEqual(got, truck) {
+		t.Errorf("round trip = %+v\nwant %+v", got, truck)
+	}
+}
+
+// TestMarshalErrors tests that unsupported values are reported
+func TestMarshalErrors(t *testing.T) {
+	if _, err := Marshal(42); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("Marshal() error = %v, want ErrInvalidSource", err)
+	}
+
+	if _, err := Marshal(testAccessory{DataPath: "x"}); !errors.Is(err, ErrMissingID) {
+		t.Errorf("Marshal() error = %v, want ErrMissingID", err)
+	}
+
+	if _, err := Marshal(struct {
+		ID string `sii:",id"`
+	}{ID: "x"}); !errors.Is(err, ErrMissingUtype) {
+		t.Errorf("Marshal() error = %v, want ErrMissingUtype", err)
+	}
+
+	var typeErr *MarshalTypeError
+
+	bad := struct {
+		ID    string            `sii:"thing,id"`
+		Utype string            `sii:"thing,utype"`
+		Map   map[string]string `sii:"map"`
+	}{ID: "thing.one"}
+	if _, err := Marshal(bad); !errors.As(err, &typeErr) || typeErr.Key != "map" {
+		t.Errorf("Marshal() error = %v, want MarshalTypeError for map", err)
+	}
+
+	badHint := struct {
+		ID    string     `sii:",id"`
+		Utype string     `sii:"thing,utype"`
+		Pos   [3]float64 `sii:"pos,int3"`
+	}{ID: "thing.one", Pos: [3]float64{1.5, 0, 0}}
+	if _, err := Marshal(badHint); !errors.As(err, &typeErr) || typeErr.Hint != "int3" {
+		t.Errorf("Marshal() error = %v, want MarshalTypeError for int3 hint", err)
+	}
+}
+
+// TestWriteUnitsRoundTrip tests that writing parsed units gives back the same units
+func TestWriteUnitsRoundTrip(t *testing.T) {
+	units := parseTestSave(t)
+
+	var buf bytes.Buffer
+	if err := WriteUnits(&buf, units); err != nil {
+		t.Fatalf("WriteUnits() error = %v", err)
+	}
+
+	reparsed, err := ParseAllUnits(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(reparsed, units) {
+		t.Errorf("round trip differs:\n%s", buf.String())
+	}
+}
+
+// TestMarshalDefaultNames tests fields tagged with options only get their snake_case name
+func TestMarshalDefaultNames(t *testing.T) {
+	v := struct {
+		ID           string  `sii:",id"`
+		Utype        string  `sii:"truck,utype"`
+		FuelRelative float32 `sii:",hexfloat"`
+		HQCity       string  `sii:",token"`
+		Odometer     int     `sii:""`
+	}{ID: "truck.one", FuelRelative: 0.5, HQCity: "berlin", Odometer: 12}
+
+	unit, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := []string{"fuel_relative", "hq_city", "odometer"}
+	if !reflect.DeepEqual(unit.Attrs.Keys(), want) {
+		t.Errorf("Marshal() keys = %v, want %v", unit.Attrs.Keys(), want)
+	}
+	if got := unit.Attrs.attrs["fuel_relative"].siiValue(); got != "&3f000000" {
+		t.Errorf("Marshal() fuel_relative = %s, want &3f000000", got)
+	}
+
+	var back struct {
+		FuelRelative float32 `sii:",hexfloat"`
+		HQCity       string  `sii:",token"`
+	}
+	if err := Unmarshal(unit, &back); err != nil || back.FuelRelative != 0.5 || back.HQCity != "berlin" {
+		t.Errorf("Unmarshal() = %+v, %v", back, err)
+	}
+}
+
+type testGarage struct {
+	ID      string        `sii:",id"`
+	Utype   string        `sii:"garage,utype"`
+	Drivers []*testDriver `sii:"drivers"`
+}
+
+type testDriver struct {
+	ID     string      `sii:",id"`
+	Utype  string      `sii:"driver,utype"`
+	Garage *testGarage `sii:"garage"`
+}
+
+// TestMarshalAllBackReference tests units pointing back at the marshalled unit don't add it twice
+func TestMarshalAllBackReference(t *testing.T) {
+	garage := &testGarage{ID: "garage.berlin"}
+	garage.Drivers = []*testDriver{
+		{ID: "driver.anna", Garage: garage},
+		{ID: "driver.ben", Garage: garage},
+	}
+
+	units, err := MarshalAll(garage)
+	if err != nil {
+		t.Fatalf("MarshalAll() error = %v", err)
+	}
+
+	want := []string{"garage.berlin", "driver.anna", "driver.ben"}
+	if got := unitIDs(units); !reflect.DeepEqual(got, want) {
+		t.Errorf("MarshalAll() ids = %v, want %v", got, want)
+	}
+	if got := units[1].Attrs.attrs["garage"].siiValue(); got != "garage.berlin" {
+		t.Errorf("MarshalAll() driver garage = %s, want garage.berlin", got)
+	}
+}
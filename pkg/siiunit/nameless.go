@@ -0,0 +1,139 @@
+package siiunit
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// namelessStep is the distance between consecutive IDs the game hands out
+	namelessStep = 0x10
+	// namelessBase is the first ID handed out when there are no nameless IDs yet,
+	// it gives the usual _nameless.XXX.XXXX.XXXX shape
+	namelessBase = 0x100_0000_0000
+)
+
+// NamelessAllocator hands out nameless IDs that don't collide with any ID it has seen
+type NamelessAllocator struct {
+	used map[uint64]struct{}
+	next uint64
+}
+
+// NewNamelessAllocator creates an allocator seeded with the IDs of units and
+// every nameless pointer they hold, so even dangling pointers are never reused
+func NewNamelessAllocator(units []Unit) *NamelessAllocator {
+	na := &NamelessAllocator{
+		used: make(map[uint64]struct{}),
+		next: namelessBase,
+	}
+
+	for _, unit := range units {
+		na.reserveUnit(unit)
+	}
+
+	return na
+}
+
+// Reserve marks id as used, it reports false if id is not nameless or already taken
+func (na *NamelessAllocator) Reserve(id string) bool {
+	n, ok := parseNameless(id)
+	if !ok {
+		return false
+	}
+	if _, taken := na.used[n]; taken {
+		return false
+	}
+
+	na.used[n] = struct{}{}
+	if n >= na.next {
+		na.next = n&^(namelessStep-1) + namelessStep
+	}
+	return true
+}
+
+// Next returns a fresh nameless ID after the highest one seen so far
+func (na *NamelessAllocator) Next() string {
+	for {
+		n := na.next
+		na.next += namelessStep
+		if _, taken := na.used[n]; !taken {
+			na.used[n] = struct{}{}
+			return formatNameless(n)
+		}
+	}
+}
+
+// reserveUnit reserves the ID of unit and the nameless pointers in its attributes
+func (na *NamelessAllocator) reserveUnit(unit Unit) {
+	na.Reserve(unit.ID)
+	for _, attr := range unit.Attrs.All() {
+		forEachToken(&attr, func(token string) {
+			na.Reserve(token)
+		})
+	}
+}
+
+// forEachToken calls fn with the value of a token attribute or each token element of an array
+func forEachToken(attr *Attribute, fn func(token string)) {
+	switch {
+	case attr.Atype == AttributeTypeString && !attr.quoted:
+		fn(attr.stringVal)
+	case attr.Atype == AttributeTypeArray && attr.arrayElemType == AttributeTypeString:
+		for i := range attr.arrayVals {
+			if !attr.arrayVals[i].quoted {
+				fn(attr.arrayVals[i].stringVal)
+			}
+		}
+	}
+}
+
+// formatNameless formats n the way the game writes nameless IDs, hex digits
+// in groups of four starting from the right, e.g. _nameless.1d4.8d36.7060
+func formatNameless(n uint64) string {
+	hex := strconv.FormatUint(n, 16)
+
+	var sb strings.Builder
+	sb.WriteString(namelessPrefix)
+
+	head := len(hex) % 4
+	if head == 0 {
+		head = 4
+	}
+	sb.WriteString(hex[:head])
+	for i := head; i < len(hex); i += 4 {
+		sb.WriteByte('.')
+		sb.WriteString(hex[i : i+4])
+	}
+
+	return sb.String()
+}
+
+// parseNameless returns the number behind a nameless ID, ok is false for any other ID
+func parseNameless(id string) (uint64, bool) {
+	hex, ok := strings.CutPrefix(id, namelessPrefix)
+	if !ok || hex == "" {
+		return 0, false
+	}
+
+	var n uint64
+	for i, seg := range strings.Split(hex, ".") {
+		if seg == "" || len(seg) > 4 || (i > 0 && len(seg) != 4) {
+			return 0, false
+		}
+		if n > (1<<64-1)>>(4*len(seg)) {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(seg, 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		n = n<<(4*len(seg)) | v
+	}
+
+	return n, true
+}
+
+// IsNameless reports if id is a nameless unit ID
+func IsNameless(id string) bool {
+	return strings.HasPrefix(id, namelessPrefix)
+}
@@ -0,0 +1,274 @@
+package siiunit
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// OwnershipTree is the tree of units formed by owning pointers. Every nameless unit
+// is owned by the unit pointing at it, e.g. a vehicle owns its accessories. Named
+// units are global and only owned through keys listed with OptOwnedKeys. The text
+// format doesn't tell owner_ptr and link_ptr attributes apart, so every pointer to a
+// nameless unit is taken as owning unless its key is listed with OptLinkKeys.
+type OwnershipTree struct {
+	// Roots are the units without an owner in document order
+	Roots []*OwnerNode
+	// Orphans are the nameless roots that aren't expected roots, see OptRootIDs
+	Orphans []*OwnerNode
+	// MultipleOwners lists every owning pointer of units with more than one owner.
+	// The first one in document order is the owner in the tree.
+	MultipleOwners map[string][]RefLocation
+	// Cycles are the units owning each other in a loop, in owner to child order.
+	// The first unit of each cycle is cut from its owner so the tree stays a tree.
+	Cycles [][]string
+
+	nodes map[string]*OwnerNode
+}
+
+// OwnerNode is a unit in the ownership tree
+type OwnerNode struct {
+	Unit     Unit
+	Owner    *OwnerNode
+	OwnedBy  RefLocation // Owning pointer, only set if Owner is
+	Children []*OwnerNode
+}
+
+type OwnershipOption func(*ownershipOptions) error
+
+type ownershipOptions struct {
+	links map[string]bool // utype.key or key
+	owned map[string]bool // utype.key or key
+	roots map[string]bool
+}
+
+// OptLinkKeys marks pointer attributes that don't own the unit they point at.
+// Keys are either "key" for any unit type or "utype.key", e.g. "player.assigned_truck".
+func OptLinkKeys(keys ...string) OwnershipOption {
+	return func(oo *ownershipOptions) error {
+		for _, key := range keys {
+			if key == "" {
+				return fmt.Errorf("OptLinkKeys: empty key")
+			}
+			oo.links[key] = true
+		}
+		return nil
+	}
+}
+
+// OptOwnedKeys marks pointer attributes that own the named units they point at as well,
+// e.g. "garage.drivers" for a garage with its drivers. Keys are given like for OptLinkKeys.
+func OptOwnedKeys(keys ...string) OwnershipOption {
+	return func(oo *ownershipOptions) error {
+		for _, key := range keys {
+			if key == "" {
+				return fmt.Errorf("OptOwnedKeys: empty key")
+			}
+			oo.owned[key] = true
+		}
+		return nil
+	}
+}
+
+// OptRootIDs sets the nameless units expected to have no owner. By default only the
+// first unit, the economy unit of a save, is.
+func OptRootIDs(ids ...string) OwnershipOption {
+	return func(oo *ownershipOptions) error {
+		if len(ids) == 0 {
+			return fmt.Errorf("OptRootIDs: need at least 1 id")
+		}
+		for _, id := range ids {
+			oo.roots[id] = true
+		}
+		return nil
+	}
+}
+
+func (oo *ownershipOptions) isLink(utype, key string) bool {
+	return oo.links[key] || oo.links[utype+"."+key]
+}
+
+func (oo *ownershipOptions) isOwned(utype, key string) bool {
+	return oo.owned[key] || oo.owned[utype+"."+key]
+}
+
+// BuildOwnershipTree rebuilds the ownership tree of units
+func BuildOwnershipTree(units []Unit, opts ...OwnershipOption) (*OwnershipTree, error) {
+	options := &ownershipOptions{
+		links: make(map[string]bool),
+		owned: make(map[string]bool),
+		roots: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidOption, err)
+		}
+	}
+	if len(options.roots) == 0 && len(units) > 0 {
+		options.roots[units[0].ID] = true
+	}
+
+	t := &OwnershipTree{
+		MultipleOwners: make(map[string][]RefLocation),
+		nodes:          make(map[string]*OwnerNode, len(units)),
+	}
+
+	// Units with a duplicate ID are left out, pointers can't tell them apart
+	var order []*OwnerNode
+	for _, unit := range units {
+		if _, exists := t.nodes[unit.ID]; !exists {
+			node := &OwnerNode{Unit: unit}
+			t.nodes[unit.ID] = node
+			order = append(order, node)
+		}
+	}
+
+	owners := make(map[string][]RefLocation)
+	for _, owner := range order {
+		unit := owner.Unit
+		for key, attr := range unit.Attrs.All() {
+			if options.isLink(unit.Utype, key) {
+				continue
+			}
+
+			ownsNamed := options.isOwned(unit.Utype, key)
+			forEachPointer(&attr, func(ptr string, index int) {
+				child, ok := t.nodes[ptr]
+				if !ok || (!IsNameless(ptr) && !ownsNamed) {
+					return
+				}
+
+				loc := RefLocation{UnitID: unit.ID, Key: key, Index: index}
+				owners[ptr] = append(owners[ptr], loc)
+				if child.Owner == nil {
+					child.Owner = owner
+					child.OwnedBy = loc
+					owner.Children = append(owner.Children, child)
+				}
+			})
+		}
+	}
+
+	for id, locs := range owners {
+		if len(locs) > 1 {
+			t.MultipleOwners[id] = locs
+		}
+	}
+
+	cut := t.cutCycles(order)
+
+	for _, node := range order {
+		if node.Owner != nil || cut[node] {
+			continue
+		}
+
+		t.Roots = append(t.Roots, node)
+		if IsNameless(node.Unit.ID) && !options.roots[node.Unit.ID] {
+			t.Orphans = append(t.Orphans, node)
+		}
+	}
+
+	return t, nil
+}
+
+// cutCycles finds units owning each other in a loop, cuts every loop open and
+// returns the nodes it cut from their owner
+func (t *OwnershipTree) cutCycles(order []*OwnerNode) map[*OwnerNode]bool {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*OwnerNode]int, len(order))
+	cuts := make(map[*OwnerNode]bool)
+
+	for _, node := range order {
+		var path []*OwnerNode
+		for node != nil && state[node] == unvisited {
+			state[node] = visiting
+			path = append(path, node)
+			node = node.Owner
+		}
+
+		if node != nil && state[node] == visiting {
+			// node is on the current path, so the owners from it on form a loop
+			start := slices.Index(path, node)
+			loop := path[start:]
+			slices.Reverse(loop)
+
+			cycle := make([]string, len(loop))
+			for i, n := range loop {
+				cycle[i] = n.Unit.ID
+			}
+			t.Cycles = append(t.Cycles, cycle)
+
+			cut := loop[0]
+			cut.Owner.Children = slices.DeleteFunc(cut.Owner.Children, func(n *OwnerNode) bool { return n == cut })
+			cut.Owner = nil
+			cut.OwnedBy = RefLocation{}
+			cuts[cut] = true
+		}
+
+		for _, n := range path {
+			state[n] = done
+		}
+	}
+
+	return cuts
+}
+
+// Node returns the node of the unit with the given ID
+func (t *OwnershipTree) Node(id string) (*OwnerNode, bool) {
+	node, ok := t.nodes[id]
+	return node, ok
+}
+
+// Walk calls fn for the node and everything it owns, depth first with owners before
+// the units they own. Returning false from fn skips the units the node owns.
+func (n *OwnerNode) Walk(fn func(node *OwnerNode, depth int) bool) {
+	n.walk(fn, 0)
+}
+
+func (n *OwnerNode) walk(fn func(node *OwnerNode, depth int) bool, depth int) {
+	if !fn(n, depth) {
+		return
+	}
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Subtree returns the units of the node and everything it owns, owners first
+func (n *OwnerNode) Subtree() []Unit {
+	var units []Unit
+	n.Walk(func(node *OwnerNode, _ int) bool {
+		units = append(units, node.Unit)
+		return true
+	})
+	return units
+}
+
+// Print writes the node and everything it owns as an indented tree, one unit per line
+// with the pointer owning it
+func (n *OwnerNode) Print(w io.Writer) error {
+	var sb strings.Builder
+	n.Walk(func(node *OwnerNode, depth int) bool {
+		sb.WriteString(strings.Repeat("  ", depth))
+		sb.WriteString(node.Unit.Utype)
+		sb.WriteString(" : ")
+		sb.WriteString(node.Unit.ID)
+		if node.Owner != nil {
+			key := node.OwnedBy.Key
+			if node.OwnedBy.Index >= 0 {
+				key = fmt.Sprintf("%s[%d]", key, node.OwnedBy.Index)
+			}
+			sb.WriteString(" (" + key + ")")
+		}
+		sb.WriteString("\n")
+		return true
+	})
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
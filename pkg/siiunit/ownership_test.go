@@ -0,0 +1,118 @@
+package siiunit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestOwnershipTree tests the tree of the test save
+func TestOwnershipTree(t *testing.T) {
+	units := parseTestSave(t)
+	tree, err := BuildOwnershipTree(units)
+	if err != nil {
+		t.Fatalf("BuildOwnershipTree() error = %v", err)
+	}
+
+	var roots []string
+	for _, node := range tree.Roots {
+		roots = append(roots, node.Unit.ID)
+	}
+	wantRoots := []string{"_nameless.1d4.8d36.7030", "company.volatile.scania_fac.paris", "company.volatile.volvo_dlr.berlin"}
+	if !reflect.DeepEqual(roots, wantRoots) {
+		t.Errorf("Roots = %v, want %v", roots, wantRoots)
+	}
+	if len(tree.Orphans) != 0 || len(tree.MultipleOwners) != 0 || len(tree.Cycles) != 0 {
+		t.Errorf("Orphans = %v, MultipleOwners = %v, Cycles = %v, want none", tree.Orphans, tree.MultipleOwners, tree.Cycles)
+	}
+
+	var sb strings.Builder
+	tree.Roots[0].Print(&sb)
+	want := `economy : _nameless.1d4.8d36.7030
+  bank : _nameless.1d4.8d36.7040 (bank)
+  player : _nameless.1d4.8d36.7050 (player)
+    vehicle : _nameless.1d4.8d36.7060 (assigned_truck)
+      vehicle_accessory : _nameless.1d4.8d36.7080 (accessories[0])
+      vehicle_accessory : _nameless.1d4.8d36.7090 (accessories[1])
+`
+	if sb.String() != want {
+		t.Errorf("Print() =\n%s\nwant\n%s", sb.String(), want)
+	}
+
+	truck, _ := tree.Node("_nameless.1d4.8d36.7060")
+	if got := unitIDs(truck.Subtree()); !reflect.DeepEqual(got, []string{"_nameless.1d4.8d36.7060", "_nameless.1d4.8d36.7080", "_nameless.1d4.8d36.7090"}) {
+		t.Errorf("Subtree() = %v", got)
+	}
+
+	// Walk can skip owned units
+	var walked []string
+	tree.Roots[0].Walk(func(node *OwnerNode, depth int) bool {
+		walked = append(walked, node.Unit.Utype)
+		return node.Unit.Utype != "player"
+	})
+	if !reflect.DeepEqual(walked, []string{"economy", "bank", "player"}) {
+		t.Errorf("Walk() = %v", walked)
+	}
+}
+
+// TestOwnershipProblems tests orphans, multiple owners and cycles are flagged
+func TestOwnershipProblems(t *testing.T) {
+	units := parseTestSave(t)
+	doc, _ := NewDocument(units)
+
+	// A second player pointing at the same truck, and a lost accessory
+	second, _ := doc.Create("player")
+	second.Attrs.Set("assigned_truck", NewTokenAttribute("_nameless.1d4.8d36.7060"))
+	lost, _ := doc.Create("vehicle_accessory")
+
+	// Two trailers owning each other
+	a, _ := doc.Create("trailer")
+	b, _ := doc.Create("trailer")
+	a.Attrs.Set("slave_trailer", NewTokenAttribute(b.ID))
+	b.Attrs.Set("slave_trailer", NewTokenAttribute(a.ID))
+
+	tree, err := BuildOwnershipTree(doc.Units())
+	if err != nil {
+		t.Fatalf("BuildOwnershipTree() error = %v", err)
+	}
+
+	var orphans []string
+	for _, node := range tree.Orphans {
+		orphans = append(orphans, node.Unit.ID)
+	}
+	if want := []string{second.ID, lost.ID}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("Orphans = %v, want %v", orphans, want)
+	}
+
+	wantOwners := map[string][]RefLocation{
+		"_nameless.1d4.8d36.7060": {
+			{UnitID: "_nameless.1d4.8d36.7050", Key: "assigned_truck", Index: -1},
+			{UnitID: second.ID, Key: "assigned_truck", Index: -1},
+		},
+	}
+	if !reflect.DeepEqual(tree.MultipleOwners, wantOwners) {
+		t.Errorf("MultipleOwners = %v, want %v", tree.MultipleOwners, wantOwners)
+	}
+
+	if len(tree.Cycles) != 1 || len(tree.Cycles[0]) != 2 {
+		t.Fatalf("Cycles = %v, want one cycle of 2 units", tree.Cycles)
+	}
+	cut, _ := tree.Node(tree.Cycles[0][0])
+	if cut.Owner != nil || len(cut.Subtree()) != 2 {
+		t.Errorf("cycle not cut open: owner %v, subtree %v", cut.Owner, unitIDs(cut.Subtree()))
+	}
+
+	// Link keys don't own, so the second player no longer competes for the truck
+	tree, _ = BuildOwnershipTree(doc.Units(), OptLinkKeys("player.assigned_truck"), OptRootIDs("_nameless.1d4.8d36.7030", second.ID))
+	if len(tree.MultipleOwners) != 0 {
+		t.Errorf("MultipleOwners with links = %v, want none", tree.MultipleOwners)
+	}
+	if len(tree.Orphans) != 2 {
+		t.Errorf("Orphans with links = %d, want the truck and the lost accessory", len(tree.Orphans))
+	}
+
+	if _, err := BuildOwnershipTree(units, OptLinkKeys("")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("BuildOwnershipTree() error = %v, want %v", err, ErrInvalidOption)
+	}
+}
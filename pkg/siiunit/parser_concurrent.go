@@ -1,6 +1,7 @@
 package siiunit
 
 import (
+	"context"
 	"io"
 	"strings"
 
@@ -8,58 +9,104 @@ import (
 )
 
 // ParseAllUnitsConcurrent parses all units from the provided content using concurrent workers.
+//
+// The result is deterministic: units come back in the order they appear in the
+// content, the same as ParseAllUnits, no matter the worker count or scheduling.
 func ParseAllUnitsConcurrent(content io.Reader, opts ...ParserOption) ([]Unit, error) {
-	options := getOptions(opts...)
+	return ParseAllUnitsConcurrentContext(context.Background(), content, opts...)
+}
 
-	group := new(errgroup.Group)
-	group.SetLimit(options.workerCount)
+// ParseAllUnitsConcurrentContext is ParseAllUnitsConcurrent but stops the reader
+// and all workers with the context error as soon as ctx is cancelled.
+func ParseAllUnitsConcurrentContext(ctx context.Context, content io.Reader, opts ...ParserOption) ([]Unit, error) {
+	options, err := getOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := newProgressReporter(options.progress)
 
-	unitDtos, err := parseDtos(content)
+	unitDtos, err := parseDtos(ctx, progress.wrap(content), options, progress)
 	if err != nil {
 		return nil, err
 	}
 
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(options.workerCount)
+
+	// Every worker writes only its own slot, which keeps the input order
 	units := make([]Unit, len(unitDtos))
 
 	for i, dto := range unitDtos {
+		// Stop handing out work once cancelled or a worker failed
+		if groupCtx.Err() != nil {
+			break
+		}
+
 		group.Go(func() error {
-			unit, err := parseUnitFromDto(dto)
+			if err := groupCtx.Err(); err != nil {
+				return err
+			}
+
+			unit, err := parseUnitFromDto(dto, options.symbols)
 			if err != nil {
 				return err
 			}
 
 			units[i] = unit
+			progress.report(1)
 			return nil
 		})
 	}
 
-	return units, group.Wait()
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	// A cancel after the last worker finished still counts as cancelled
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	options.index.Add(units...)
+
+	return units, nil
 }
 
-func parseUnitFromDto(dto *unitDto) (Unit, error) {
+func parseUnitFromDto(dto *unitDto, symbols *SymbolTable) (Unit, error) {
 	unit := Unit{
-		Utype: dto.Utype,
-		ID:    dto.ID,
-		Attrs: *newAttributes(),
+		Utype:   symbols.Intern(dto.Utype),
+		ID:      dto.ID,
+		Attrs:   newAttributes(),
+		Comment: dto.Comment,
 	}
 
 	var prevLine string
 	var definingFirstArrLine string // This will track the first line that defines an array attribute for multi-line arrays
+	var comments []string
 
 	for _, line := range dto.Body {
-		if containsArrSyntax(line) {
+		if text, ok := commentText(line); ok {
+			comments = append(comments, text)
+		} else if containsArrSyntax(line) {
 			if !containsArrSyntax(prevLine) {
 				definingFirstArrLine = prevLine
 			}
 
-			err := buildAttributeArray(line, definingFirstArrLine, &unit.Attrs)
+			err := buildAttributeArray(line, definingFirstArrLine, unit.Attrs, symbols)
 			if err != nil {
 				return Unit{}, err
 			}
+			attachComment(unit.Attrs, arrayKey(line), comments)
+			comments = nil
 		} else if strings.Contains(line, ": ") {
 			splitLine := strings.Split(line, ": ")
 
-			unit.Attrs.addAttribute(splitLine[0], splitLine[1])
+			if err := unit.Attrs.addAttribute(splitLine[0], splitLine[1], symbols); err != nil {
+				return Unit{}, err
+			}
+			attachComment(unit.Attrs, splitLine[0], comments)
+			comments = nil
 
 			prevLine = line
 		}
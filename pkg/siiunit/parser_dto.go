@@ -2,39 +2,77 @@ package siiunit
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"strings"
 )
 
 type unitDto struct {
-	Utype string
-	ID    string
-	Body  []string
+	Utype   string
+	ID      string
+	Comment string
+	Body    []string
 }
 
-func parseDtos(content io.Reader) ([]*unitDto, error) {
+// parseDtos splits the content into unit headers and raw body lines.
+// It stops with the context error as soon as ctx is cancelled.
+func parseDtos(ctx context.Context, content io.Reader, options *parserOptions, progress *progressReporter) ([]*unitDto, error) {
 	scanner := bufio.NewScanner(content)
 
 	var currDto *unitDto
 	var dtos []*unitDto
 
 	inBlock := false
+	skipBlock := false
+	var comments []string // Comment lines before the next unit header
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
+		// Comments in a unit are part of its body, a brace in them doesn't close it
+		if text, ok := commentText(line); ok {
+			switch {
+			case !inBlock:
+				comments = append(comments, text)
+			case !skipBlock:
+				currDto.Body = append(currDto.Body, line)
+			}
+			continue
+		}
+
 		if strings.Contains(line, "{") && strings.Contains(line, " : ") {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
 			inBlock = true
 
 			line = strings.TrimRight(line, " {")
 			splitLine := strings.Split(line, " : ")
 
+			// Filtered out units are still tracked until their closing brace
+			skipBlock = !options.keepUnit(splitLine[0], splitLine[1])
+			if skipBlock {
+				comments = nil
+				continue
+			}
+
 			currDto = &unitDto{
-				Utype: splitLine[0],
-				ID:    splitLine[1],
-				Body:  make([]string, 0),
+				Utype:   splitLine[0],
+				ID:      splitLine[1],
+				Comment: strings.Join(comments, "\n"),
+				Body:    make([]string, 0),
 			}
+			comments = nil
+
+			continue
+		}
 
+		if skipBlock {
+			if strings.Contains(line, "}") {
+				inBlock = false
+				skipBlock = false
+			}
 			continue
 		}
 
@@ -44,6 +82,7 @@ func parseDtos(content io.Reader) ([]*unitDto, error) {
 
 		if strings.Contains(line, "}") && inBlock {
 			dtos = append(dtos, currDto)
+			progress.report(0)
 			currDto = nil
 			inBlock = false
 		}
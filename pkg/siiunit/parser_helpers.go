@@ -1,6 +1,7 @@
 package siiunit
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -19,11 +20,14 @@ import (
 // - interpret values according to context provided by definingLine,
 // - validate attribute names and values and resolve duplicates according to format rules,
 // - report detailed errors for malformed input.
-func buildAttributeArray(line, definingLine string, currAttrs *Attributes) error {
+func buildAttributeArray(line, definingLine string, currAttrs *Attributes, symbols *SymbolTable) error {
 	definingLineSplit := strings.Split(definingLine, ": ")
 	arrKey := definingLineSplit[0]
 
-	attr := currAttrs.attrs[arrKey]
+	attr, ok := currAttrs.attrs[arrKey]
+	if !ok {
+		return fmt.Errorf("%w: array element %s without its length", ErrParsingFailed, line)
+	}
 
 	// Check if the attribute is already an array or not
 	if attr.Atype != AttributeTypeArray {
@@ -37,7 +41,12 @@ func buildAttributeArray(line, definingLine string, currAttrs *Attributes) error
 
 	// Append the value to the array
 	lineSplit := strings.Split(line, ": ")
-	attr.appendToArray(lineSplit[1])
+	if err := attr.appendToArray(lineSplit[1]); err != nil {
+		return fmt.Errorf("failed to add attribute %s: %w", arrKey, err)
+	}
+	if len(attr.arrayVals) > 0 {
+		symbols.internAttribute(&attr.arrayVals[len(attr.arrayVals)-1])
+	}
 
 	return nil
 }
@@ -46,3 +55,38 @@ func containsArrSyntax(line string) bool {
 	splitLine := strings.Split(line, ": ")
 	return strings.Contains(splitLine[0], "[")
 }
+
+// commentText returns the text of a # or // comment line without the marker and the
+// space following it
+func commentText(line string) (string, bool) {
+	var text string
+	switch {
+	case strings.HasPrefix(line, "#"):
+		text = line[1:]
+	case strings.HasPrefix(line, "//"):
+		text = line[2:]
+	default:
+		return "", false
+	}
+	return strings.TrimPrefix(text, " "), true
+}
+
+// attachComment adds the comment lines read before an attribute to its comment
+func attachComment(attrs *Attributes, key string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	comment := strings.Join(lines, "\n")
+	if prev := attrs.Comment(key); prev != "" {
+		comment = prev + "\n" + comment
+	}
+	attrs.SetComment(key, comment)
+}
+
+// arrayKey returns the attribute key of an array element line, e.g. accessories for
+// accessories[1]
+func arrayKey(line string) string {
+	key, _, _ := strings.Cut(line, "[")
+	return key
+}
@@ -2,34 +2,48 @@ package siiunit
 
 // This shit is really useless IMO but it's a nice pattern I wanted to explore more
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 const DEFAULT_WORKER_COUNT = 4
 
-func getOptions(opts ...ParserOption) *parserOptions {
+var ErrInvalidOption = errors.New("invalid parser option")
+
+// getOptions applies opts over the defaults, the first failing option is returned wrapped in ErrInvalidOption
+func getOptions(opts ...ParserOption) (*parserOptions, error) {
 	options := &parserOptions{
 		workerCount: DEFAULT_WORKER_COUNT,
+		symbols:     NewSymbolTable(),
 	}
 
-	var err error
 	for _, opt := range opts {
-		err = opt(options)
-		if err != nil {
-			panic(err)
+		if err := opt(options); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidOption, err)
 		}
 	}
 
-	return options
+	return options, nil
 }
 
 type parserOptions struct {
 	workerCount int
+	unitFilter  func(utype, id string) bool
+	progress    func(Progress)
+	symbols     *SymbolTable
+	index       *Index
+}
+
+// keepUnit reports if the unit with the given header should be decoded
+func (po *parserOptions) keepUnit(utype, id string) bool {
+	return po.unitFilter == nil || po.unitFilter(utype, id)
 }
 
 type ParserOption func(*parserOptions) error
 
-// OptGoroutinesCount is if you want to control the ammount to goroutines the parser uses.
-// Minimum 2 goroutines.
+// OptWorkerCount is if you want to control the ammount of worker goroutines the concurrent parser uses.
+// Minimum 1 worker.
 func OptWorkerCount(count int) ParserOption {
 	return func(po *parserOptions) error {
 		if count < 1 {
@@ -41,3 +55,84 @@ func OptWorkerCount(count int) ParserOption {
 		return nil
 	}
 }
+
+// OptUnitTypes only decodes units of the given types, every other unit is skipped
+// without parsing its body.
+func OptUnitTypes(types ...string) ParserOption {
+	return func(po *parserOptions) error {
+		if len(types) == 0 {
+			return fmt.Errorf("OptUnitTypes: need at least 1 unit type")
+		}
+
+		wanted := make(map[string]struct{}, len(types))
+		for _, t := range types {
+			wanted[t] = struct{}{}
+		}
+
+		return OptUnitFilter(func(utype, _ string) bool {
+			_, ok := wanted[utype]
+			return ok
+		})(po)
+	}
+}
+
+// OptUnitFilter only decodes units the predicate returns true for. The predicate
+// gets the unit type and ID from the unit header, before the body is parsed.
+func OptUnitFilter(keep func(utype, id string) bool) ParserOption {
+	return func(po *parserOptions) error {
+		if keep == nil {
+			return fmt.Errorf("OptUnitFilter: predicate is nil")
+		}
+
+		prev := po.unitFilter
+		if prev == nil {
+			po.unitFilter = keep
+			return nil
+		}
+
+		// Multiple filters must all match
+		po.unitFilter = func(utype, id string) bool {
+			return prev(utype, id) && keep(utype, id)
+		}
+
+		return nil
+	}
+}
+
+// OptProgress calls fn with the bytes read and units parsed so far while parsing.
+// The callback is called from the parsing goroutines, so it should return quickly.
+func OptProgress(fn func(Progress)) ParserOption {
+	return func(po *parserOptions) error {
+		if fn == nil {
+			return fmt.Errorf("OptProgress: callback is nil")
+		}
+
+		po.progress = fn
+
+		return nil
+	}
+}
+
+// OptSymbolTable interns unit types, attribute keys and tokens through st instead of
+// a fresh table per parse. Sharing one table across parses keeps batch scans small.
+// A nil table turns interning off.
+func OptSymbolTable(st *SymbolTable) ParserOption {
+	return func(po *parserOptions) error {
+		po.symbols = st
+
+		return nil
+	}
+}
+
+// OptIndex adds the parsed units to idx once parsing succeeded
+func OptIndex(idx *Index) ParserOption {
+	return func(po *parserOptions) error {
+		if idx == nil {
+			return fmt.Errorf("OptIndex: index is nil")
+		}
+
+		po.index = idx
+
+		return nil
+	}
+}
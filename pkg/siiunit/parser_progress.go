@@ -0,0 +1,64 @@
+package siiunit
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Progress is passed to the OptProgress callback while parsing
+type Progress struct {
+	// BytesRead is the number of bytes consumed from the content reader so far
+	BytesRead int64
+	// UnitsParsed is the number of units decoded so far
+	UnitsParsed int
+}
+
+// progressReporter counts bytes and units and forwards them to the user callback.
+// The callback is never called concurrently, even from the concurrent parser.
+type progressReporter struct {
+	fn        func(Progress)
+	bytesRead atomic.Int64
+
+	mu          sync.Mutex
+	unitsParsed int
+}
+
+func newProgressReporter(fn func(Progress)) *progressReporter {
+	return &progressReporter{fn: fn}
+}
+
+// wrap returns a reader that counts the bytes read from r
+func (pr *progressReporter) wrap(r io.Reader) io.Reader {
+	if pr.fn == nil {
+		return r
+	}
+	return &countingReader{r: r, n: &pr.bytesRead}
+}
+
+// report calls the callback with the current counts, adding parsed units
+func (pr *progressReporter) report(parsed int) {
+	if pr.fn == nil {
+		return
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.unitsParsed += parsed
+	pr.fn(Progress{
+		BytesRead:   pr.bytesRead.Load(),
+		UnitsParsed: pr.unitsParsed,
+	})
+}
+
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n.Add(int64(n))
+	return n, err
+}
@@ -2,13 +2,28 @@ package siiunit
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"strings"
 )
 
-// ReadAllDescriptors does panic (I'm to lazy to handle errors atm)
-func ParseAllUnits(content io.Reader) ([]Unit, error) {
-	scanner := bufio.NewScanner(content)
+// ParseAllUnits parses all units from the provided content sequentially.
+// The worker count option is ignored.
+func ParseAllUnits(content io.Reader, opts ...ParserOption) ([]Unit, error) {
+	return ParseAllUnitsContext(context.Background(), content, opts...)
+}
+
+// ParseAllUnitsContext is ParseAllUnits but stops with the context error
+// as soon as ctx is cancelled.
+func ParseAllUnitsContext(ctx context.Context, content io.Reader, opts ...ParserOption) ([]Unit, error) {
+	options, err := getOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := newProgressReporter(options.progress)
+
+	scanner := bufio.NewScanner(progress.wrap(content))
 
 	var units []Unit
 
@@ -17,32 +32,64 @@ func ParseAllUnits(content io.Reader) ([]Unit, error) {
 	var currUnit *Unit
 	var currAttrs *Attributes
 	var beginBlock = false
+	var skipBlock = false
+	var comments []string // Comment lines waiting for the next unit or attribute
 
 	for scanner.Scan() {
 		line := scanner.Text()
 		line = strings.TrimSpace(line)
 
+		if text, ok := commentText(line); ok {
+			if !skipBlock {
+				comments = append(comments, text)
+			}
+			continue
+		}
+
 		if strings.Contains(line, "{") && strings.Contains(line, " : ") {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
 			beginBlock = true
 
 			line = strings.TrimRight(line, " {")
 			splitLine := strings.Split(line, " : ")
 
+			// Filtered out units are still tracked until their closing brace
+			skipBlock = !options.keepUnit(splitLine[0], splitLine[1])
+			if skipBlock {
+				comments = nil
+				continue
+			}
+
 			currUnit = &Unit{
-				Utype: splitLine[0],
-				ID:    splitLine[1],
+				Utype:   options.symbols.Intern(splitLine[0]),
+				ID:      splitLine[1],
+				Comment: strings.Join(comments, "\n"),
 			}
+			comments = nil
 
 			currAttrs = newAttributes()
 
 			continue
 		}
 
+		if skipBlock {
+			if strings.Contains(line, "}") {
+				beginBlock = false
+				skipBlock = false
+			}
+			continue
+		}
+
 		if strings.Contains(line, "}") && beginBlock {
-			currUnit.Attrs = *currAttrs
+			currUnit.Attrs = currAttrs
 			units = append(units, *currUnit)
+			progress.report(1)
 			currUnit = nil
 			beginBlock = false
+			comments = nil
 		}
 
 		if beginBlock && containsArrSyntax(line) {
@@ -50,10 +97,12 @@ func ParseAllUnits(content io.Reader) ([]Unit, error) {
 				firstArrLine = prevLine
 			}
 
-			err := buildAttributeArray(line, firstArrLine, currAttrs)
+			err := buildAttributeArray(line, firstArrLine, currAttrs, options.symbols)
 			if err != nil {
 				return nil, err
 			}
+			attachComment(currAttrs, arrayKey(line), comments)
+			comments = nil
 
 			continue
 		}
@@ -65,11 +114,24 @@ func ParseAllUnits(content io.Reader) ([]Unit, error) {
 
 			splitLine := strings.Split(line, ": ")
 
-			currAttrs.addAttribute(splitLine[0], splitLine[1])
+			if err := currAttrs.addAttribute(splitLine[0], splitLine[1], options.symbols); err != nil {
+				return nil, err
+			}
+			attachComment(currAttrs, splitLine[0], comments)
+			comments = nil
 		}
 
 		prevLine = line
 	}
 
-	return units, scanner.Err()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Report the bytes read after the last unit
+	progress.report(0)
+
+	options.index.Add(units...)
+
+	return units, nil
 }
@@ -0,0 +1,446 @@
+package siiunit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+const testSave = `SiiNunit
+{
+economy : _nameless.1d4.8d36.7030 {
+ bank: _nameless.1d4.8d36.7040
+ player: _nameless.1d4.8d36.7050
+ companies: 2
+ companies[0]: company.volatile.scania_fac.paris
+ companies[1]: company.volatile.volvo_dlr.berlin
+ game_time: 24771
+}
+bank : _nameless.1d4.8d36.7040 {
+ money_account: 1523644
+ coinsurance_fixed: &3f800000
+}
+player : _nameless.1d4.8d36.7050 {
+ hq_city: berlin
+ trailer_placement: (-35478.76, 29.54, 7826.03) (0.9998; 0, -0.0179, 0)
+ assigned_truck: _nameless.1d4.8d36.7060
+}
+company : company.volatile.scania_fac.paris {
+ permanent_data: company.permanent.scania_fac
+ job_offer: 1
+ job_offer[0]: _nameless.1d4.8d36.7070
+}
+job_offer_data : _nameless.1d4.8d36.7070 {
+ target: "volvo_dlr.berlin"
+ expiration_time: 24900
+}
+company : company.volatile.volvo_dlr.berlin {
+ permanent_data: company.permanent.volvo_dlr
+ job_offer: 0
+}
+vehicle : _nameless.1d4.8d36.7060 {
+ odometer: 512345
+ accessories: 2
+ accessories[0]: _nameless.1d4.8d36.7080
+ accessories[1]: _nameless.1d4.8d36.7090
+}
+vehicle_accessory : _nameless.1d4.8d36.7080 {
+ data_path: "/def/vehicle/truck/volvo.fh16_2012/engine/d13c540.sii"
+}
+vehicle_accessory : _nameless.1d4.8d36.7090 {
+ data_path: "/def/vehicle/truck/volvo.fh16_2012/chassis/4x2.sii"
+}
+}
+`
+
+// generateSave builds a synthetic save with roughly the shape of a real one:
+// an economy, a handful of companies and many job offers and vehicles.
+func generateSave(jobOffers int, seed uint64) string {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	cities := []string{"berlin", "paris", "praha", "wien", "calais", "lyon"}
+	dealers := []string{"scania_fac", "volvo_dlr", "renault_dlr", "daf_dlr"}
+
+	var sb strings.Builder
+	id := uint64(0x1d48d367030)
+	nextID := func() string {
+		id += 0x10
+		return formatTestNameless(id)
+	}
+
+	sb.WriteString("SiiNunit\n{\n")
+	fmt.Fprintf(&sb, "economy : %s {\n game_time: %d\n unlocked_dealers: %d\n", nextID(), rng.IntN(100000), len(dealers))
+	for i, d := range dealers {
+		fmt.Fprintf(&sb, " unlocked_dealers[%d]: %s\n", i, d)
+	}
+	sb.WriteString("}\n")
+
+	for i := range jobOffers {
+		company := fmt.Sprintf("company.volatile.%s.%s", dealers[i%len(dealers)], cities[i%len(cities)])
+		fmt.Fprintf(&sb, "job_offer_data : %s {\n", nextID())
+		fmt.Fprintf(&sb, " target: \"%s.%s\"\n", dealers[rng.IntN(len(dealers))], cities[rng.IntN(len(cities))])
+		fmt.Fprintf(&sb, " source_company: %s\n", company)
+		fmt.Fprintf(&sb, " cargo: cargo.%s\n", dealers[rng.IntN(len(dealers))])
+		fmt.Fprintf(&sb, " urgency: %d\n", rng.IntN(3))
+		fmt.Fprintf(&sb, " shortest_distance_km: %d\n", rng.IntN(3000))
+		fmt.Fprintf(&sb, " ferry_price: &%08x\n", rng.Uint32()&0x7f7fffff)
+		fmt.Fprintf(&sb, " trailer_place: (%d, %d, %d) (1; 0, 0, 0)\n", rng.IntN(1000), rng.IntN(100), rng.IntN(1000))
+		fmt.Fprintf(&sb, " is_special: %t\n", rng.IntN(2) == 0)
+		sb.WriteString("}\n")
+
+		if i%10 == 0 {
+			accessories := []string{nextID(), nextID()}
+			fmt.Fprintf(&sb, "vehicle : %s {\n odometer: %d\n accessories: %d\n", nextID(), rng.IntN(1000000), len(accessories))
+			for j, a := range accessories {
+				fmt.Fprintf(&sb, " accessories[%d]: %s\n", j, a)
+			}
+			fmt.Fprintf(&sb, " wear: (%.4f, %.4f)\n}\n", rng.Float64(), rng.Float64())
+		}
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// formatTestNameless formats n the way the game writes nameless IDs
+func formatTestNameless(n uint64) string {
+	s := fmt.Sprintf("%x", n)
+	var parts []string
+	for len(s) > 4 {
+		parts = append([]string{s[len(s)-4:]}, parts...)
+		s = s[:len(s)-4]
+	}
+	return "_nameless." + strings.Join(append([]string{s}, parts...), ".")
+}
+
+func unitIDs(units []Unit) []string {
+	ids := make([]string, len(units))
+	for i, u := range units {
+		ids[i] = u.ID
+	}
+	return ids
+}
+
+// TestParseAllUnits tests both parsers on a small save
+func TestParseAllUnits(t *testing.T) {
+	parsers := map[string]func(string, ...ParserOption) ([]Unit, error){
+		"sequential": func(s string, opts ...ParserOption) ([]Unit, error) {
+			return ParseAllUnits(strings.NewReader(s), opts...)
+		},
+		"concurrent": func(s string, opts ...ParserOption) ([]Unit, error) {
+			return ParseAllUnitsConcurrent(strings.NewReader(s), opts...)
+		},
+	}
+
+	for name, parse := range parsers {
+		t.Run(name, func(t *testing.T) {
+			units, err := parse(testSave)
+			if err != nil {
+				t.Fatalf("parse error = %v", err)
+			}
+
+			if len(units) != 9 {
+				t.Fatalf("parsed %d units, want 9", len(units))
+			}
+
+			money, _ := units[1].Attrs.Get("money_account")
+			if val, err := money.Int(); err != nil || val != 1523644 {
+				t.Errorf("money_account = %v, %v, want 1523644", val, err)
+			}
+
+			companies, _ := units[0].Attrs.Get("companies")
+			arr, err := companies.Arr()
+			if err != nil || len(arr) != 2 {
+				t.Errorf("companies = %v, %v, want 2 elements", arr, err)
+			}
+		})
+	}
+}
+
+// TestParseUnitFilter tests that filtered out units are skipped
+func TestParseUnitFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []ParserOption
+		wantIDs []string
+	}{
+		{
+			name:    "by unit type",
+			opts:    []ParserOption{OptUnitTypes("company", "player")},
+			wantIDs: []string{"_nameless.1d4.8d36.7050", "company.volatile.scania_fac.paris", "company.volatile.volvo_dlr.berlin"},
+		},
+		{
+			name: "by predicate",
+			opts: []ParserOption{OptUnitFilter(func(utype, id string) bool {
+				return strings.HasSuffix(id, ".berlin")
+			})},
+			wantIDs: []string{"company.volatile.volvo_dlr.berlin"},
+		},
+		{
+			name: "combined filters",
+			opts: []ParserOption{
+				OptUnitTypes("vehicle_accessory", "vehicle"),
+				OptUnitFilter(func(utype, id string) bool { return id != "_nameless.1d4.8d36.7080" }),
+			},
+			wantIDs: []string{"_nameless.1d4.8d36.7060", "_nameless.1d4.8d36.7090"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq, err := ParseAllUnits(strings.NewReader(testSave), tt.opts...)
+			if err != nil {
+				t.Fatalf("ParseAllUnits() error = %v", err)
+			}
+
+			con, err := ParseAllUnitsConcurrent(strings.NewReader(testSave), tt.opts...)
+			if err != nil {
+				t.Fatalf("ParseAllUnitsConcurrent() error = %v", err)
+			}
+
+			if ids := unitIDs(seq); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("ParseAllUnits() ids = %v, want %v", ids, tt.wantIDs)
+			}
+
+			if ids := unitIDs(con); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("ParseAllUnitsConcurrent() ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// TestParseContextCancel tests that both parsers stop when the context is cancelled
+func TestParseContextCancel(t *testing.T) {
+	parsers := map[string]func(context.Context, string, ...ParserOption) ([]Unit, error){
+		"sequential": func(ctx context.Context, s string, opts ...ParserOption) ([]Unit, error) {
+			return ParseAllUnitsContext(ctx, strings.NewReader(s), opts...)
+		},
+		"concurrent": func(ctx context.Context, s string, opts ...ParserOption) ([]Unit, error) {
+			return ParseAllUnitsConcurrentContext(ctx, strings.NewReader(s), opts...)
+		},
+	}
+
+	for name, parse := range parsers {
+		t.Run(name+" already cancelled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			units, err := parse(ctx, testSave)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want context.Canceled", err)
+			}
+			if units != nil {
+				t.Errorf("units = %v, want nil", units)
+			}
+		})
+
+		t.Run(name+" cancelled while parsing", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			calls := 0
+			_, err := parse(ctx, testSave, OptProgress(func(p Progress) {
+				calls++
+				if calls == 2 {
+					cancel()
+				}
+			}))
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want context.Canceled", err)
+			}
+		})
+	}
+}
+
+// TestParseProgress tests the final progress report of both parsers
+func TestParseProgress(t *testing.T) {
+	var last Progress
+	units, err := ParseAllUnits(strings.NewReader(testSave), OptProgress(func(p Progress) {
+		if p.UnitsParsed < last.UnitsParsed || p.BytesRead < last.BytesRead {
+			t.Errorf("progress went backwards: %+v after %+v", p, last)
+		}
+		last = p
+	}))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	if last.UnitsParsed != len(units) || last.BytesRead != int64(len(testSave)) {
+		t.Errorf("last progress = %+v, want %d units and %d bytes", last, len(units), len(testSave))
+	}
+
+	last = Progress{}
+	units, err = ParseAllUnitsConcurrent(strings.NewReader(testSave), OptProgress(func(p Progress) {
+		if p.UnitsParsed < last.UnitsParsed {
+			t.Errorf("progress went backwards: %+v after %+v", p, last)
+		}
+		last = p
+	}))
+	if err != nil {
+		t.Fatalf("ParseAllUnitsConcurrent() error = %v", err)
+	}
+
+	if last.UnitsParsed != len(units) || last.BytesRead != int64(len(testSave)) {
+		t.Errorf("last progress = %+v, want %d units and %d bytes", last, len(units), len(testSave))
+	}
+}
+
+// TestParseInterning tests that a shared symbol table is used across parses
+func TestParseInterning(t *testing.T) {
+	symbols := NewSymbolTable()
+
+	first, err := ParseAllUnits(strings.NewReader(testSave), OptSymbolTable(symbols))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	second, err := ParseAllUnitsConcurrent(strings.NewReader(testSave), OptSymbolTable(symbols))
+	if err != nil {
+		t.Fatalf("ParseAllUnitsConcurrent() error = %v", err)
+	}
+
+	if symbols.Len() == 0 {
+		t.Fatal("symbol table is empty after parsing")
+	}
+
+	// company units share the interned unit type string
+	if unsafe.StringData(first[3].Utype) != unsafe.StringData(second[5].Utype) {
+		t.Error("Utype of company units is not interned across parses")
+	}
+
+	// Tokens are interned, quoted strings are not
+	a, _ := first[2].Attrs.Get("hq_city")
+	b, _ := second[2].Attrs.Get("hq_city")
+	aVal, _ := a.String()
+	bVal, _ := b.String()
+	if unsafe.StringData(aVal) != unsafe.StringData(bVal) {
+		t.Error("hq_city token is not interned across parses")
+	}
+
+	if symbols.Intern("job_offer_data") != "job_offer_data" {
+		t.Error("Intern() changed the string value")
+	}
+}
+
+// BenchmarkParseAllUnits reports the heap kept alive by the parsed units with and without interning
+func BenchmarkParseAllUnits(b *testing.B) {
+	save := generateSave(5000, 1)
+
+	for _, interning := range []bool{true, false} {
+		b.Run(fmt.Sprintf("interning=%t", interning), func(b *testing.B) {
+			opts := []ParserOption{}
+			if !interning {
+				opts = append(opts, OptSymbolTable(nil))
+			}
+
+			b.ReportAllocs()
+			var heap int64
+			for b.Loop() {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				units, err := ParseAllUnits(strings.NewReader(save), opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				heap = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+				runtime.KeepAlive(units)
+			}
+			b.ReportMetric(float64(heap), "live-B")
+		})
+	}
+}
+
+// TestConcurrentMatchesSequential tests that the concurrent parser returns exactly
+// what the sequential one does, in the same order. Run with -race.
+func TestConcurrentMatchesSequential(t *testing.T) {
+	for _, seed := range []uint64{1, 2, 3} {
+		save := generateSave(500, seed)
+
+		want, err := ParseAllUnits(strings.NewReader(save))
+		if err != nil {
+			t.Fatalf("ParseAllUnits() error = %v", err)
+		}
+
+		for _, workers := range []int{1, 2, 8, 32} {
+			t.Run(fmt.Sprintf("seed=%d/workers=%d", seed, workers), func(t *testing.T) {
+				t.Parallel()
+
+				got, err := ParseAllUnitsConcurrent(strings.NewReader(save), OptWorkerCount(workers))
+				if err != nil {
+					t.Fatalf("ParseAllUnitsConcurrent() error = %v", err)
+				}
+
+				if len(got) != len(want) {
+					t.Fatalf("parsed %d units, want %d", len(got), len(want))
+				}
+
+				for i := range want {
+					if !reflect.DeepEqual(got[i], want[i]) {
+						t.Fatalf("unit %d differs:\ngot  %v\nwant %v", i, got[i], want[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestParseInvalidOption tests that bad options are returned as errors
+func TestParseInvalidOption(t *testing.T) {
+	opts := [][]ParserOption{
+		{OptWorkerCount(0)},
+		{OptUnitTypes()},
+		{OptUnitFilter(nil)},
+		{OptProgress(nil)},
+	}
+
+	for _, o := range opts {
+		if _, err := ParseAllUnits(strings.NewReader(testSave), o...); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("ParseAllUnits() error = %v, want ErrInvalidOption", err)
+		}
+
+		if _, err := ParseAllUnitsConcurrent(strings.NewReader(testSave), o...); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("ParseAllUnitsConcurrent() error = %v, want ErrInvalidOption", err)
+		}
+	}
+}
+
+// TestParseArrayShapes tests arrays mixing whole and fractional floats keep every element
+// and malformed values fail the parse
+func TestParseArrayShapes(t *testing.T) {
+	const mixed = "SiiNunit\n{\nbank : bank.player {\n arr: 3\n arr[0]: 1\n arr[1]: 1.5\n arr[2]: 2\n}\n}\n"
+	parsers := map[string]func(r *strings.Reader) ([]Unit, error){
+		"sequential": func(r *strings.Reader) ([]Unit, error) { return ParseAllUnits(r) },
+		"concurrent": func(r *strings.Reader) ([]Unit, error) { return ParseAllUnitsConcurrent(r) },
+	}
+
+	for name, parse := range parsers {
+		units, err := parse(strings.NewReader(mixed))
+		if err != nil {
+			t.Fatalf("%s parse error = %v", name, err)
+		}
+		if arr, err := GetAs[[]float64](units[0].Attrs, "arr"); err != nil || !reflect.DeepEqual(arr, []float64{1, 1.5, 2}) {
+			t.Errorf("%s arr = %v, %v, want [1 1.5 2]", name, arr, err)
+		}
+
+		for _, bad := range []string{
+			"SiiNunit\n{\nbank : bank.player {\n money: &zz\n}\n}\n",
+			"SiiNunit\n{\nbank : bank.player {\n arr: 2\n arr[0]: 1\n arr[1]: &zz\n}\n}\n",
+			"SiiNunit\n{\nbank : bank.player {\n arr: 2\n arr[0]: 1\n arr[1]: \"x\"\n}\n}\n",
+		} {
+			if _, err := parse(strings.NewReader(bad)); err == nil {
+				t.Errorf("%s parse of %q should fail", name, bad)
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package siiunit
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Query selects units by type and attribute values and can follow pointers from the
+// selected units to the units they reference. A query is written as
+//
+//	vehicle[odometer > 500000].accessories -> vehicle_accessory[data_path ~ "volvo.fh16"] | @id, data_path
+//
+// Each step selects units of a type, or any type with *, optionally filtered by
+// predicates in brackets that all have to match. ".key -> step" follows the pointers
+// stored under key, a single pointer or an array of them, to the next step. The
+// columns after | pick what Table shows, @id and @utype are the unit ID and type.
+//
+// Predicates compare an attribute with =, !=, <, <=, >, >= or ~ (contains), or just
+// name it to check it exists. Numbers compare numerically with int and float
+// attributes, everything else compares as text. An array matches if any element does.
+type Query struct {
+	steps   []queryStep
+	columns []string
+}
+
+type queryStep struct {
+	via   string // Pointer attribute followed from the previous step, empty for the first
+	utype string // * matches any type
+	preds []queryPredicate
+}
+
+type queryPredicate struct {
+	key   string
+	op    string // Empty if the predicate only checks the attribute exists
+	value string
+	num   float64
+	isNum bool
+}
+
+// Eval runs the query against units and returns the matching units of the last step.
+// Units reached more than once are only returned the first time.
+func (q *Query) Eval(units []Unit) []Unit {
+	byID := make(map[string]Unit, len(units))
+	for _, unit := range units {
+		byID[unit.ID] = unit
+	}
+
+	var matched []Unit
+	for _, unit := range units {
+		if q.steps[0].match(unit) {
+			matched = append(matched, unit)
+		}
+	}
+
+	for _, step := range q.steps[1:] {
+		var next []Unit
+		seen := make(map[string]bool)
+
+		for _, unit := range matched {
+			attr, ok := unit.Attrs.lookup(step.via)
+			if !ok {
+				continue
+			}
+
+			forEachToken(attr, func(ptr string) {
+				target, ok := byID[ptr]
+				if !ok || seen[ptr] || !step.match(target) {
+					return
+				}
+				seen[ptr] = true
+				next = append(next, target)
+			})
+		}
+
+		matched = next
+	}
+
+	return matched
+}
+
+// Table runs the query and returns the matching units as rows of the query columns,
+// or of @utype and @id if the query has none
+func (q *Query) Table(units []Unit) *Table {
+	columns := q.columns
+	if len(columns) == 0 {
+		columns = []string{"@utype", "@id"}
+	}
+
+	t := &Table{Columns: columns}
+	for _, unit := range q.Eval(units) {
+		row := make([]string, len(columns))
+		for i, col := range columns {
+			switch col {
+			case "@id":
+				row[i] = unit.ID
+			case "@utype":
+				row[i] = unit.Utype
+			default:
+				if attr, ok := unit.Attrs.lookup(col); ok {
+					row[i] = attr.text()
+				}
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	return t
+}
+
+// Table is the tabular result of a query
+type Table struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// Write writes the table as aligned text columns with a header line
+func (t *Table) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	io.WriteString(tw, strings.Join(t.Columns, "\t")+"\n")
+	for _, row := range t.Rows {
+		io.WriteString(tw, strings.Join(row, "\t")+"\n")
+	}
+
+	return tw.Flush()
+}
+
+func (s *queryStep) match(unit Unit) bool {
+	if s.utype != "*" && s.utype != unit.Utype {
+		return false
+	}
+
+	for _, pred := range s.preds {
+		attr, ok := unit.Attrs.lookup(pred.key)
+		if !ok || !pred.match(attr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *queryPredicate) match(attr *Attribute) bool {
+	if p.op == "" {
+		return true
+	}
+
+	if attr.Atype == AttributeTypeArray {
+		for i := range attr.arrayVals {
+			if p.match(&attr.arrayVals[i]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if p.op == "~" {
+		return strings.Contains(attr.text(), p.value)
+	}
+
+	if f, err := attr.AsFloat(); err == nil && p.isNum {
+		return compareResult(p.op, cmpFloat(f, p.num))
+	}
+	return compareResult(p.op, strings.Compare(attr.text(), p.value))
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compareResult tells if the result of a three way comparison satisfies op
+func compareResult(op string, c int) bool {
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	default:
+		return false
+	}
+}
+
+// text returns the attribute value for comparisons and tables, strings without
+// quotes, floats in decimal and arrays as their elements in brackets
+func (a *Attribute) text() string {
+	switch a.Atype {
+	case AttributeTypeString:
+		return a.stringVal
+	case AttributeTypeFloat:
+		return strconv.FormatFloat(a.floatVal, 'f', -1, 64)
+	case AttributeTypeArray:
+		parts := make([]string, len(a.arrayVals))
+		for i := range a.arrayVals {
+			parts[i] = a.arrayVals[i].text()
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		decimal := *a
+		decimal.hexFloat = false
+		return decimal.siiValue()
+	}
+}
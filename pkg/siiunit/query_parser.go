@@ -0,0 +1,211 @@
+package siiunit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrQuerySyntax = errors.New("query syntax error")
+
+// queryOperators is ordered so two character operators are tried first
+var queryOperators = []string{">=", "<=", "!=", "=", ">", "<", "~"}
+
+// ParseQuery parses a query, see Query for the syntax
+func ParseQuery(src string) (*Query, error) {
+	p := &queryParser{src: src}
+
+	q, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("%w at offset %d: %w", ErrQuerySyntax, p.pos, err)
+	}
+	return q, nil
+}
+
+type queryParser struct {
+	src string
+	pos int
+}
+
+func (p *queryParser) parse() (*Query, error) {
+	q := &Query{}
+
+	step, err := p.step("")
+	if err != nil {
+		return nil, err
+	}
+	q.steps = append(q.steps, step)
+
+	for p.accept(".") {
+		via, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept("->") {
+			return nil, errors.New("expected -> after pointer attribute")
+		}
+
+		step, err := p.step(via)
+		if err != nil {
+			return nil, err
+		}
+		q.steps = append(q.steps, step)
+	}
+
+	if p.accept("|") {
+		for {
+			col, err := p.column()
+			if err != nil {
+				return nil, err
+			}
+			q.columns = append(q.columns, col)
+
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q", p.src[p.pos:])
+	}
+
+	return q, nil
+}
+
+// step parses a unit type with optional predicates
+func (p *queryParser) step(via string) (queryStep, error) {
+	step := queryStep{via: via}
+
+	if p.accept("*") {
+		step.utype = "*"
+	} else {
+		utype, err := p.ident()
+		if err != nil {
+			return step, err
+		}
+		step.utype = utype
+	}
+
+	if !p.accept("[") {
+		return step, nil
+	}
+
+	for {
+		pred, err := p.predicate()
+		if err != nil {
+			return step, err
+		}
+		step.preds = append(step.preds, pred)
+
+		if p.accept("]") {
+			return step, nil
+		}
+		if !p.accept(",") {
+			return step, errors.New("expected , or ]")
+		}
+	}
+}
+
+func (p *queryParser) predicate() (queryPredicate, error) {
+	key, err := p.ident()
+	if err != nil {
+		return queryPredicate{}, err
+	}
+	pred := queryPredicate{key: key}
+
+	p.skipSpaces()
+	for _, op := range queryOperators {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			pred.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if pred.op == "" {
+		return pred, nil
+	}
+
+	pred.value, err = p.value()
+	if err != nil {
+		return pred, err
+	}
+
+	if f, err := strconv.ParseFloat(pred.value, 64); err == nil {
+		pred.num, pred.isNum = f, true
+	}
+	return pred, nil
+}
+
+// value parses a quoted string or a bare token up to the next space, comma or bracket
+func (p *queryParser) value() (string, error) {
+	p.skipSpaces()
+
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		end := strings.IndexByte(p.src[p.pos+1:], '"')
+		if end < 0 {
+			return "", errors.New("unterminated string")
+		}
+		val := p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return val, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t,]", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", errors.New("expected value")
+	}
+	return p.src[start:p.pos], nil
+}
+
+// column parses an attribute key or @id / @utype
+func (p *queryParser) column() (string, error) {
+	if p.accept("@") {
+		name, err := p.ident()
+		if err != nil {
+			return "", err
+		}
+		if name != "id" && name != "utype" {
+			return "", fmt.Errorf("unknown column @%s", name)
+		}
+		return "@" + name, nil
+	}
+	return p.ident()
+}
+
+// ident parses a unit type or attribute key
+func (p *queryParser) ident() (string, error) {
+	p.skipSpaces()
+
+	start := p.pos
+	for p.pos < len(p.src) && isIdentByte(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", errors.New("expected name")
+	}
+	return p.src[start:p.pos], nil
+}
+
+// accept consumes s if it comes next
+func (p *queryParser) accept(s string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) skipSpaces() {
+	p.pos = skipSpaces(p.src, p.pos)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,119 @@
+package siiunit
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestQueryEval tests type filters, predicates and pointer traversal
+func TestQueryEval(t *testing.T) {
+	units := parseTestSave(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "vehicle_accessory", want: []string{"_nameless.1d4.8d36.7080", "_nameless.1d4.8d36.7090"}},
+		{query: "*[permanent_data]", want: []string{"company.volatile.scania_fac.paris", "company.volatile.volvo_dlr.berlin"}},
+		{query: "vehicle[odometer > 500000]", want: []string{"_nameless.1d4.8d36.7060"}},
+		{query: "vehicle[odometer > 600000]", want: nil},
+		{query: "bank[money_account >= 1523644, coinsurance_fixed = 1]", want: []string{"_nameless.1d4.8d36.7040"}},
+		{query: "bank[coinsurance_fixed < 1]", want: nil},
+		{query: "player[hq_city != paris]", want: []string{"_nameless.1d4.8d36.7050"}},
+		{query: `job_offer_data[target = "volvo_dlr.berlin"]`, want: []string{"_nameless.1d4.8d36.7070"}},
+		{query: "economy[companies ~ volvo_dlr]", want: []string{"_nameless.1d4.8d36.7030"}},
+		{query: "player[trailer_placement ~ 29.54]", want: []string{"_nameless.1d4.8d36.7050"}},
+		{
+			query: `vehicle[odometer > 500000].accessories -> vehicle_accessory[data_path ~ "volvo.fh16_2012/engine"]`,
+			want:  []string{"_nameless.1d4.8d36.7080"},
+		},
+		{
+			query: "economy.player -> player.assigned_truck -> vehicle.accessories -> *",
+			want:  []string{"_nameless.1d4.8d36.7080", "_nameless.1d4.8d36.7090"},
+		},
+		{query: "economy.companies -> company.job_offer -> job_offer_data", want: []string{"_nameless.1d4.8d36.7070"}},
+		{query: "economy.companies -> bank", want: nil},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) error = %v", tt.query, err)
+			continue
+		}
+
+		got := q.Eval(units)
+		var ids []string
+		if len(got) > 0 {
+			ids = unitIDs(got)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Eval(%q) = %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+}
+
+// TestQueryTable tests projections to table output
+func TestQueryTable(t *testing.T) {
+	units := parseTestSave(t)
+
+	q, err := ParseQuery("vehicle.accessories -> vehicle_accessory | @id, data_path, missing")
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+
+	var sb strings.Builder
+	if err := q.Table(units).Write(&sb); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// The empty last column leaves trailing spaces behind
+	var lines []string
+	for line := range strings.Lines(sb.String()) {
+		lines = append(lines, strings.TrimRight(line, " \n"))
+	}
+	want := []string{
+		"@id                      data_path                                              missing",
+		"_nameless.1d4.8d36.7080  /def/vehicle/truck/volvo.fh16_2012/engine/d13c540.sii",
+		"_nameless.1d4.8d36.7090  /def/vehicle/truck/volvo.fh16_2012/chassis/4x2.sii",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Write() =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	q, _ = ParseQuery("bank | money_account, coinsurance_fixed, @utype")
+	table := q.Table(units)
+	if want := [][]string{{"1523644", "1", "bank"}}; !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("Table() rows = %v, want %v", table.Rows, want)
+	}
+
+	q, _ = ParseQuery("economy[game_time]")
+	table = q.Table(units)
+	if want := [][]string{{"economy", "_nameless.1d4.8d36.7030"}}; !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("Table() default rows = %v, want %v", table.Rows, want)
+	}
+}
+
+// TestParseQueryErrors tests malformed queries are rejected
+func TestParseQueryErrors(t *testing.T) {
+	queries := []string{
+		"",
+		"vehicle[",
+		"vehicle[odometer >]",
+		"vehicle[odometer > 1",
+		"vehicle.accessories",
+		"vehicle.accessories ->",
+		`vehicle[data_path = "open]`,
+		"vehicle | @name",
+		"vehicle |",
+		"vehicle extra",
+	}
+
+	for _, query := range queries {
+		if _, err := ParseQuery(query); !errors.Is(err, ErrQuerySyntax) {
+			t.Errorf("ParseQuery(%q) error = %v, want %v", query, err, ErrQuerySyntax)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package siiunit
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RefLocation is an attribute holding a pointer to a unit
+type RefLocation struct {
+	UnitID string
+	Key    string
+	Index  int // Array index, -1 for a single pointer
+}
+
+// String returns the location as unitID.key or unitID.key[index]
+func (l RefLocation) String() string {
+	if l.Index < 0 {
+		return l.UnitID + "." + l.Key
+	}
+	return fmt.Sprintf("%s.%s[%d]", l.UnitID, l.Key, l.Index)
+}
+
+// RefIndex maps unit IDs to the attributes pointing at them. Pointers are tokens
+// shaped like unit IDs, nameless IDs or dotted names, so tokens like city names are
+// left out. Pointers to units that don't exist are indexed as well.
+type RefIndex struct {
+	to   map[string][]RefLocation // Target ID to the locations pointing at it
+	from map[refSource][]string   // Targets of every source attribute, to drop them on change
+}
+
+type refSource struct {
+	unitID string
+	key    string
+}
+
+// NewRefIndex builds the reference index of units. It is a snapshot, use
+// Document.References for an index that follows edits.
+func NewRefIndex(units []Unit) *RefIndex {
+	ri := &RefIndex{
+		to:   make(map[string][]RefLocation),
+		from: make(map[refSource][]string),
+	}
+
+	for _, unit := range units {
+		ri.addUnit(unit)
+	}
+	return ri
+}
+
+// To returns the locations pointing at the unit with the given ID, sorted by unit ID,
+// key and index
+func (ri *RefIndex) To(id string) []RefLocation {
+	locs := slices.Clone(ri.to[id])
+	slices.SortFunc(locs, func(a, b RefLocation) int {
+		return cmp.Or(
+			strings.Compare(a.UnitID, b.UnitID),
+			strings.Compare(a.Key, b.Key),
+			cmp.Compare(a.Index, b.Index),
+		)
+	})
+	return locs
+}
+
+func (ri *RefIndex) addUnit(unit Unit) {
+	for _, key := range unit.Attrs.Keys() {
+		ri.update(unit.ID, unit.Attrs, key)
+	}
+}
+
+func (ri *RefIndex) removeUnit(unit Unit) {
+	for _, key := range unit.Attrs.Keys() {
+		ri.drop(refSource{unit.ID, key})
+	}
+}
+
+// update reindexes the attribute stored under key in the unit with the given ID
+func (ri *RefIndex) update(unitID string, attrs *Attributes, key string) {
+	src := refSource{unitID, key}
+	ri.drop(src)
+
+	attr, ok := attrs.lookup(key)
+	if !ok {
+		return
+	}
+
+	forEachPointer(attr, func(ptr string, index int) {
+		ri.to[ptr] = append(ri.to[ptr], RefLocation{UnitID: unitID, Key: key, Index: index})
+		ri.from[src] = append(ri.from[src], ptr)
+	})
+}
+
+// forEachPointer calls fn with every pointer held by attr and its array index,
+// -1 if attr is not an array
+func forEachPointer(attr *Attribute, fn func(ptr string, index int)) {
+	if attr.Atype == AttributeTypeString && !attr.quoted {
+		if isPointerToken(attr.stringVal) {
+			fn(attr.stringVal, -1)
+		}
+		return
+	}
+
+	if attr.Atype != AttributeTypeArray || attr.arrayElemType != AttributeTypeString {
+		return
+	}
+	for i := range attr.arrayVals {
+		if elem := &attr.arrayVals[i]; !elem.quoted && isPointerToken(elem.stringVal) {
+			fn(elem.stringVal, i)
+		}
+	}
+}
+
+// drop removes the locations of a source attribute
+func (ri *RefIndex) drop(src refSource) {
+	for _, target := range ri.from[src] {
+		locs := slices.DeleteFunc(ri.to[target], func(l RefLocation) bool {
+			return l.UnitID == src.unitID && l.Key == src.key
+		})
+		if len(locs) == 0 {
+			delete(ri.to, target)
+		} else {
+			ri.to[target] = locs
+		}
+	}
+	delete(ri.from, src)
+}
+
+// isPointerToken reports if a token looks like a unit ID
+func isPointerToken(token string) bool {
+	return token != "null" && (IsNameless(token) || strings.Contains(token, "."))
+}
@@ -0,0 +1,91 @@
+package siiunit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func refStrings(locs []RefLocation) []string {
+	var strs []string
+	for _, l := range locs {
+		strs = append(strs, l.String())
+	}
+	return strs
+}
+
+// TestRefIndex tests the static reference index of parsed units
+func TestRefIndex(t *testing.T) {
+	ri := NewRefIndex(parseTestSave(t))
+
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{id: "_nameless.1d4.8d36.7060", want: []string{"_nameless.1d4.8d36.7050.assigned_truck"}},
+		{id: "_nameless.1d4.8d36.7090", want: []string{"_nameless.1d4.8d36.7060.accessories[1]"}},
+		{id: "company.volatile.volvo_dlr.berlin", want: []string{"_nameless.1d4.8d36.7030.companies[1]"}},
+		{id: "company.permanent.scania_fac", want: []string{"company.volatile.scania_fac.paris.permanent_data"}},
+		{id: "_nameless.1d4.8d36.7030", want: nil},
+		{id: "berlin", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := refStrings(ri.To(tt.id)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("To(%s) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+// TestDocumentReferences tests the document reference index follows edits
+func TestDocumentReferences(t *testing.T) {
+	doc := parseTestDocument(t)
+	const truck = "_nameless.1d4.8d36.7060"
+	const acc = "_nameless.1d4.8d36.7090"
+
+	check := func(step, id string, want ...string) {
+		t.Helper()
+		if got := refStrings(doc.References(id)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: References(%s) = %v, want %v", step, id, got, want)
+		}
+	}
+
+	check("parsed", acc, "_nameless.1d4.8d36.7060.accessories[1]")
+
+	unit, _ := doc.Unit(truck)
+	unit.Attrs.InsertAt("accessories", 0, NewTokenAttribute(acc))
+	check("insert", acc, "_nameless.1d4.8d36.7060.accessories[0]", "_nameless.1d4.8d36.7060.accessories[2]")
+
+	unit.Attrs.RemoveAt("accessories", 2)
+	unit.Attrs.Rename("accessories", "addons")
+	check("rename", acc, "_nameless.1d4.8d36.7060.addons[0]")
+
+	player, _ := doc.Unit("_nameless.1d4.8d36.7050")
+	player.Attrs.Set("trailer", NewTokenAttribute(acc))
+	check("set", acc, "_nameless.1d4.8d36.7050.trailer", "_nameless.1d4.8d36.7060.addons[0]")
+
+	player.Attrs.Delete("trailer")
+	check("delete", acc, "_nameless.1d4.8d36.7060.addons[0]")
+
+	// Clones are tracked, deleted units stop pointing anywhere
+	clone, _ := doc.Clone(truck, "")
+	check("clone", acc, "_nameless.1d4.8d36.7060.addons[0]", clone.ID+".addons[0]")
+
+	doc.Delete(truck, RefNull)
+	check("delete unit", acc, clone.ID+".addons[0]")
+	check("delete unit", truck)
+
+	// Fixups of a delete are picked up
+	doc.Delete(acc, RefDrop)
+	check("fixup", acc)
+
+	// Transactions, also when undone
+	tx := doc.Begin()
+	tx.Set("_nameless.1d4.8d36.7030", "bank", NewTokenAttribute("_nameless.1d4.8d36.7080"))
+	tx.Commit()
+	check("tx", "_nameless.1d4.8d36.7040")
+	doc.Undo()
+	check("undo", "_nameless.1d4.8d36.7040", "_nameless.1d4.8d36.7030.bank")
+
+	mapping := doc.RenumberNameless()
+	check("renumber", mapping["_nameless.1d4.8d36.7040"], mapping["_nameless.1d4.8d36.7030"]+".bank")
+}
@@ -0,0 +1,165 @@
+package siiunit
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+)
+
+// Schema describes the attributes of every unit type found in a set of sample units.
+// It can be saved as JSON and loaded again, e.g. to feed the siigen code generator.
+type Schema struct {
+	Units []*UnitSchema `json:"units"`
+}
+
+// UnitSchema describes the attributes of one unit type
+type UnitSchema struct {
+	Utype   string         `json:"utype"`
+	Samples int            `json:"samples"` // Number of units the schema was inferred from
+	Fields  []*FieldSchema `json:"fields"`  // In the order they were first seen
+}
+
+// FieldSchema describes one attribute of a unit type
+type FieldSchema struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`           // Attribute type name, "any" if samples disagree
+	Array bool   `json:"array"`          // Type is the element type of an array
+	Seen  int    `json:"seen"`           // Number of samples that have the attribute
+	Token bool   `json:"token"`          // Strings are unquoted tokens or pointers
+	Hex   bool   `json:"hex"`            // Floats are written in &xxxxxxxx form
+	Mixed bool   `json:"mixed"`          // Some samples were ints where others were floats
+	Empty bool   `json:"empty,omitzero"` // Only seen as empty arrays so far
+}
+
+// Optional reports if some samples of the unit type don't have the field
+func (f *FieldSchema) Optional(us *UnitSchema) bool {
+	return f.Seen < us.Samples
+}
+
+// InferSchema builds a schema from sample units. Adding more samples, e.g. from
+// several saves, with Schema.Add merges optional and rare fields.
+func InferSchema(units []Unit) *Schema {
+	s := &Schema{}
+	s.Add(units)
+	return s
+}
+
+// Unit returns the schema of a unit type
+func (s *Schema) Unit(utype string) (*UnitSchema, bool) {
+	for _, us := range s.Units {
+		if us.Utype == utype {
+			return us, true
+		}
+	}
+	return nil, false
+}
+
+// Add merges more sample units into the schema
+func (s *Schema) Add(units []Unit) {
+	for _, unit := range units {
+		us, ok := s.Unit(unit.Utype)
+		if !ok {
+			us = &UnitSchema{Utype: unit.Utype}
+			s.Units = append(s.Units, us)
+		}
+
+		us.add(unit)
+	}
+}
+
+func (us *UnitSchema) add(unit Unit) {
+	us.Samples++
+
+	for key, attr := range unit.Attrs.All() {
+		idx := slices.IndexFunc(us.Fields, func(f *FieldSchema) bool { return f.Key == key })
+		if idx < 0 {
+			us.Fields = append(us.Fields, &FieldSchema{Key: key})
+			idx = len(us.Fields) - 1
+		}
+
+		us.Fields[idx].add(&attr)
+	}
+}
+
+func (f *FieldSchema) add(attr *Attribute) {
+	f.Seen++
+
+	// An empty array is only its "key: 0" line, which parses as an int
+	if attr.Atype == AttributeTypeInt && attr.intVal == 0 && (f.Array || f.Type == "") {
+		if f.Type == "" {
+			f.Empty = true
+		}
+		return
+	}
+
+	if attr.Atype == AttributeTypeArray {
+		if !f.Array && f.Type != "" && !f.Empty {
+			f.Type = "any"
+		}
+		f.Array = true
+		f.Empty = false
+
+		for i := range attr.arrayVals {
+			f.merge(&attr.arrayVals[i])
+		}
+		return
+	}
+
+	if f.Array && !f.Empty {
+		f.Type = "any"
+		return
+	}
+	f.Empty = false
+
+	f.merge(attr)
+}
+
+// merge widens the field type so it fits attr as well
+func (f *FieldSchema) merge(attr *Attribute) {
+	name := attributeTypeNames[attr.Atype]
+
+	f.Token = f.Token || (attr.Atype == AttributeTypeString && !attr.quoted)
+	f.Hex = f.Hex || attr.hexFloat
+
+	switch {
+	case f.Type == "" || f.Type == name:
+		f.Type = name
+	case f.Type == "any":
+	case widerType(f.Type, name) != "":
+		f.Type = widerType(f.Type, name)
+		f.Mixed = true
+	default:
+		f.Type = "any"
+	}
+}
+
+// widerType returns the float type of an int/float pair with the same shape
+func widerType(a, b string) string {
+	pairs := map[[2]string]string{
+		{"int", "float"}:   "float",
+		{"int2", "float2"}: "float2",
+		{"int3", "float3"}: "float3",
+		{"int4", "float4"}: "float4",
+	}
+
+	if t, ok := pairs[[2]string{a, b}]; ok {
+		return t
+	}
+	return pairs[[2]string{b, a}]
+}
+
+// WriteJSON writes the schema as indented JSON
+func (s *Schema) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// ReadSchema reads a schema written by WriteJSON
+func ReadSchema(r io.Reader) (*Schema, error) {
+	s := &Schema{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
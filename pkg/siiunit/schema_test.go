@@ -0,0 +1,72 @@
+package siiunit
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestInferSchema tests merging attribute types across samples
+func TestInferSchema(t *testing.T) {
+	schema := InferSchema(parseTestSave(t))
+
+	company, ok := schema.Unit("company")
+	if !ok {
+		t.Fatal("schema has no company unit")
+	}
+
+	if company.Samples != 2 || len(company.Fields) != 2 {
+		t.Fatalf("company schema = %+v", company)
+	}
+
+	permanent := company.Fields[0]
+	if permanent.Key != "permanent_data" || permanent.Type != "string" || !permanent.Token || permanent.Optional(company) {
+		t.Errorf("permanent_data = %+v", permanent)
+	}
+
+	// One company has job offers, the other only "job_offer: 0"
+	jobOffer := company.Fields[1]
+	if jobOffer.Key != "job_offer" || jobOffer.Type != "string" || !jobOffer.Array || jobOffer.Seen != 2 {
+		t.Errorf("job_offer = %+v", jobOffer)
+	}
+
+	bank, _ := schema.Unit("bank")
+	if f := bank.Fields[1]; f.Type != "float" || !f.Hex {
+		t.Errorf("coinsurance_fixed = %+v", f)
+	}
+
+	// More samples widen int to float and make fields optional
+	extra := Unit{Utype: "bank", ID: "_nameless.1", Attrs: newAttributes()}
+	extra.Attrs.addAttribute("coinsurance_fixed", "1", nil)
+	extra.Attrs.addAttribute("loan_limit", "(1, 2)", nil)
+	schema.Add([]Unit{extra})
+
+	if f := bank.Fields[1]; f.Type != "float" || !f.Mixed || f.Seen != 2 {
+		t.Errorf("coinsurance_fixed after merge = %+v", f)
+	}
+	if f := bank.Fields[0]; !f.Optional(bank) {
+		t.Errorf("money_account after merge = %+v, want optional", f)
+	}
+	if f := bank.Fields[2]; f.Key != "loan_limit" || f.Type != "int2" || !f.Optional(bank) {
+		t.Errorf("loan_limit = %+v", f)
+	}
+}
+
+// TestSchemaJSON tests writing and reading back a schema
+func TestSchemaJSON(t *testing.T) {
+	schema := InferSchema(parseTestSave(t))
+
+	var buf bytes.Buffer
+	if err := schema.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	read, err := ReadSchema(&buf)
+	if err != nil {
+		t.Fatalf("ReadSchema() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(read, schema) {
+		t.Errorf("ReadSchema() = %+v, want %+v", read, schema)
+	}
+}
@@ -0,0 +1,256 @@
+package siiunit
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// snapshotChunkSize is the number of units copied when one of them is edited
+const snapshotChunkSize = 256
+
+// Snapshot is an immutable version of a document. Edits return a new snapshot that
+// shares everything but the edited unit and its chunk with the old one, so any number
+// of goroutines can read a snapshot while another one derives new versions from it.
+//
+// Units returned by a snapshot are copies, editing them doesn't change the snapshot.
+// Use Update to derive a version with the edit.
+type Snapshot struct {
+	version int
+	chunks  [][]Unit
+	index   map[string]int // Shared between versions with the same unit IDs
+	length  int
+}
+
+// NewSnapshot creates the first version from units. The units are copied so
+// editing them afterwards doesn't affect the snapshot.
+func NewSnapshot(units []Unit) (*Snapshot, error) {
+	cloned := make([]Unit, len(units))
+	for i, unit := range units {
+		cloned[i] = unit.Clone(unit.ID)
+	}
+	return newSnapshot(0, cloned)
+}
+
+func newSnapshot(version int, units []Unit) (*Snapshot, error) {
+	s := &Snapshot{
+		version: version,
+		index:   make(map[string]int, len(units)),
+		length:  len(units),
+	}
+
+	for i, unit := range units {
+		if _, exists := s.index[unit.ID]; exists {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateID, unit.ID)
+		}
+		s.index[unit.ID] = i
+	}
+
+	for chunk := range slices.Chunk(units, snapshotChunkSize) {
+		s.chunks = append(s.chunks, chunk)
+	}
+
+	return s, nil
+}
+
+// Version returns the number of edits made since the first snapshot
+func (s *Snapshot) Version() int {
+	return s.version
+}
+
+// Len returns the number of units
+func (s *Snapshot) Len() int {
+	return s.length
+}
+
+// Unit returns a copy of the unit with the given ID
+func (s *Snapshot) Unit(id string) (Unit, bool) {
+	i, ok := s.index[id]
+	if !ok {
+		return Unit{}, false
+	}
+	unit := s.at(i)
+	return unit.Clone(unit.ID), true
+}
+
+// Resolve is a UnitResolver for the snapshot
+func (s *Snapshot) Resolve(id string) (Unit, bool) {
+	return s.Unit(id)
+}
+
+func (s *Snapshot) at(i int) Unit {
+	return s.chunks[i/snapshotChunkSize][i%snapshotChunkSize]
+}
+
+// All returns an iterator over copies of the units in document order
+func (s *Snapshot) All() iter.Seq[Unit] {
+	return func(yield func(Unit) bool) {
+		for unit := range s.shared() {
+			if !yield(unit.Clone(unit.ID)) {
+				return
+			}
+		}
+	}
+}
+
+// shared returns an iterator over the units without copying them, the units
+// are shared with other versions and must not be edited
+func (s *Snapshot) shared() iter.Seq[Unit] {
+	return func(yield func(Unit) bool) {
+		for _, chunk := range s.chunks {
+			for _, unit := range chunk {
+				if !yield(unit) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Units returns copies of the units in document order
+func (s *Snapshot) Units() []Unit {
+	return slices.Collect(s.All())
+}
+
+// Document returns an editable copy of the snapshot
+func (s *Snapshot) Document() (*Document, error) {
+	return NewDocument(s.Units())
+}
+
+// Update returns a new version where fn has edited a copy of the attributes of the
+// unit with the given ID. The snapshot itself is left alone, also when fn fails.
+func (s *Snapshot) Update(id string, fn func(attrs *Attributes) error) (*Snapshot, error) {
+	i, ok := s.index[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnitNotFound, id)
+	}
+
+	unit := s.at(i).Clone(id)
+	if err := fn(unit.Attrs); err != nil {
+		return nil, err
+	}
+
+	next := &Snapshot{
+		version: s.version + 1,
+		chunks:  slices.Clone(s.chunks),
+		index:   s.index,
+		length:  s.length,
+	}
+
+	c := i / snapshotChunkSize
+	next.chunks[c] = slices.Clone(s.chunks[c])
+	next.chunks[c][i%snapshotChunkSize] = unit
+
+	return next, nil
+}
+
+// Set returns a new version with attr stored under key in the unit with the given ID
+func (s *Snapshot) Set(id, key string, attr Attribute) (*Snapshot, error) {
+	return s.Update(id, func(attrs *Attributes) error {
+		return attrs.Set(key, attr)
+	})
+}
+
+// Add returns a new version with a copy of unit appended
+func (s *Snapshot) Add(unit Unit) (*Snapshot, error) {
+	if _, exists := s.index[unit.ID]; exists {
+		return nil, fmt.Errorf("%w: %s", ErrDuplicateID, unit.ID)
+	}
+
+	next := &Snapshot{
+		version: s.version + 1,
+		chunks:  slices.Clone(s.chunks),
+		index:   maps.Clone(s.index),
+		length:  s.length + 1,
+	}
+	next.index[unit.ID] = s.length
+
+	unit = unit.Clone(unit.ID)
+	last := len(next.chunks) - 1
+	if last < 0 || len(next.chunks[last]) == snapshotChunkSize {
+		next.chunks = append(next.chunks, []Unit{unit})
+	} else {
+		// Clip so the append never writes into the backing array of the old version
+		next.chunks[last] = append(slices.Clip(next.chunks[last]), unit)
+	}
+
+	return next, nil
+}
+
+// Remove returns a new version without the unit with the given ID. Pointers to it
+// are fixed up like Document.Delete does, which copies every unit that changes.
+func (s *Snapshot) Remove(id string, fixup RefFixup) (*Snapshot, error) {
+	if _, ok := s.index[id]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnitNotFound, id)
+	}
+
+	units := make([]Unit, 0, s.length-1)
+	for unit := range s.shared() {
+		if unit.ID == id {
+			continue
+		}
+
+		if fixup != RefKeep && refersTo(unit, id) {
+			unit = unit.Clone(unit.ID)
+			for _, attr := range unit.Attrs.attrs {
+				rewriteAttribute(attr, func(ptr string) (string, bool) {
+					if ptr != id {
+						return ptr, true
+					}
+					return "null", fixup == RefNull
+				})
+			}
+		}
+		units = append(units, unit)
+	}
+
+	return newSnapshot(s.version+1, units)
+}
+
+// refersTo reports if unit holds a pointer to id
+func refersTo(unit Unit, id string) bool {
+	found := false
+	for _, attr := range unit.Attrs.All() {
+		forEachToken(&attr, func(token string) {
+			found = found || token == id
+		})
+	}
+	return found
+}
+
+// SnapshotStore holds the current snapshot of a document for concurrent use.
+// Readers load the current version without locking, writers are serialized.
+type SnapshotStore struct {
+	mu      sync.Mutex
+	current atomic.Pointer[Snapshot]
+}
+
+// NewSnapshotStore creates a store holding s
+func NewSnapshotStore(s *Snapshot) *SnapshotStore {
+	store := &SnapshotStore{}
+	store.current.Store(s)
+	return store
+}
+
+// Load returns the current snapshot
+func (ss *SnapshotStore) Load() *Snapshot {
+	return ss.current.Load()
+}
+
+// Update replaces the current snapshot with the one fn derives from it.
+// Nothing changes if fn returns an error.
+func (ss *SnapshotStore) Update(fn func(s *Snapshot) (*Snapshot, error)) (*Snapshot, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	next, err := fn(ss.current.Load())
+	if err != nil {
+		return nil, err
+	}
+
+	ss.current.Store(next)
+	return next, nil
+}
@@ -0,0 +1,196 @@
+package siiunit
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func writeTestUnits(t *testing.T, units []Unit) string {
+	t.Helper()
+
+	var sb strings.Builder
+	if err := WriteUnits(&sb, units); err != nil {
+		t.Fatalf("WriteUnits() error = %v", err)
+	}
+	return sb.String()
+}
+
+// TestSnapshotEdits tests edits produce new versions and leave old ones alone
+func TestSnapshotEdits(t *testing.T) {
+	units := parseTestSave(t)
+	v0, err := NewSnapshot(units)
+	if err != nil {
+		t.Fatalf("NewSnapshot() error = %v", err)
+	}
+	before := writeTestUnits(t, v0.Units())
+
+	// Editing the source units doesn't reach the snapshot
+	units[2].Attrs.Set("hq_city", NewTokenAttribute("calais"))
+
+	v1, err := v0.Set("_nameless.1d4.8d36.7050", "hq_city", NewTokenAttribute("paris"))
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	v2, err := v1.Add(Unit{Utype: "bank", ID: "_nameless.1d4.8d36.70a0", Attrs: NewAttributes()})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	v3, err := v2.Remove("_nameless.1d4.8d36.7080", RefDrop)
+	if err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+
+	if got := writeTestUnits(t, v0.Units()); got != before {
+		t.Errorf("edits changed the first version:\n%s", got)
+	}
+
+	player, _ := v1.Unit("_nameless.1d4.8d36.7050")
+	if city, _ := GetAs[string](player.Attrs, "hq_city"); city != "paris" {
+		t.Errorf("hq_city = %v, want paris", city)
+	}
+	if _, ok := v1.Unit("_nameless.1d4.8d36.70a0"); ok {
+		t.Error("Add() changed the previous version")
+	}
+
+	truck, _ := v3.Unit("_nameless.1d4.8d36.7060")
+	if acc, _ := GetAs[[]string](truck.Attrs, "accessories"); len(acc) != 1 {
+		t.Errorf("accessories after Remove = %v, want one", acc)
+	}
+	truck, _ = v2.Unit("_nameless.1d4.8d36.7060")
+	if acc, _ := GetAs[[]string](truck.Attrs, "accessories"); len(acc) != 2 {
+		t.Errorf("Remove() changed the previous version: %v", acc)
+	}
+
+	if v3.Version() != 3 || v3.Len() != 9 || v2.Len() != 10 {
+		t.Errorf("Version() = %d, Len() = %d/%d", v3.Version(), v3.Len(), v2.Len())
+	}
+
+	if _, err := v0.Set("missing", "key", NewIntAttribute(1)); !errors.Is(err, ErrUnitNotFound) {
+		t.Errorf("Set(missing) error = %v, want %v", err, ErrUnitNotFound)
+	}
+	if _, err := v0.Add(units[1]); !errors.Is(err, ErrDuplicateID) {
+		t.Errorf("Add(duplicate) error = %v, want %v", err, ErrDuplicateID)
+	}
+
+	failed := errors.New("failed")
+	if _, err := v0.Update("_nameless.1d4.8d36.7040", func(attrs *Attributes) error {
+		attrs.Delete("money_account")
+		return failed
+	}); !errors.Is(err, failed) {
+		t.Errorf("Update() error = %v, want %v", err, failed)
+	}
+	if got := writeTestUnits(t, v0.Units()); got != before {
+		t.Error("failed Update() changed the snapshot")
+	}
+}
+
+// TestSnapshotSharing tests an edit only copies the chunk of the edited unit
+func TestSnapshotSharing(t *testing.T) {
+	units, err := ParseAllUnits(strings.NewReader(generateSave(500, 1)))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+
+	v0, _ := NewSnapshot(units)
+	last := units[len(units)-1]
+	v1, err := v0.Set(last.ID, "edited", NewBoolAttribute(true))
+	if err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	for i := range v0.chunks {
+		shared := &v0.chunks[i][0] == &v1.chunks[i][0]
+		if edited := i == len(v0.chunks)-1; shared == edited {
+			t.Errorf("chunk %d shared = %v, want %v", i, shared, !edited)
+		}
+	}
+}
+
+// TestSnapshotStoreConcurrent runs readers against a store while a writer edits it
+func TestSnapshotStoreConcurrent(t *testing.T) {
+	s, _ := NewSnapshot(parseTestSave(t))
+	store := NewSnapshotStore(s)
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 200 {
+				snap := store.Load()
+				bank, _ := snap.Unit("_nameless.1d4.8d36.7040")
+				money, _ := GetAs[int64](bank.Attrs, "money_account")
+				loan, _ := GetAs[int64](bank.Attrs, "loan")
+
+				// Both attributes are set in the same update
+				if money != 1523644+loan {
+					t.Errorf("version %d: money_account = %d with loan %d", snap.Version(), money, loan)
+					return
+				}
+			}
+		})
+	}
+
+	for i := range 100 {
+		_, err := store.Update(func(s *Snapshot) (*Snapshot, error) {
+			return s.Update("_nameless.1d4.8d36.7040", func(attrs *Attributes) error {
+				attrs.Set("loan", NewIntAttribute(int64(i)))
+				return attrs.Set("money_account", NewIntAttribute(int64(1523644+i)))
+			})
+		})
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	wg.Wait()
+
+	if v := store.Load().Version(); v != 100 {
+		t.Errorf("Version() = %d, want 100", v)
+	}
+}
+
+// TestSnapshotReadersEditCopies tests readers editing the units they get don't
+// reach the snapshot or race with Update, run it with -race
+func TestSnapshotReadersEditCopies(t *testing.T) {
+	s, _ := NewSnapshot(parseTestSave(t))
+	store := NewSnapshotStore(s)
+	before := writeTestUnits(t, s.Units())
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			for range 100 {
+				snap := store.Load()
+				bank, _ := snap.Unit("_nameless.1d4.8d36.7040")
+				bank.Attrs.Set("money_account", NewIntAttribute(0))
+				for unit := range snap.All() {
+					unit.Attrs.Set("seen", NewBoolAttribute(true))
+				}
+				for _, unit := range snap.Units() {
+					unit.Attrs.Delete("seen")
+				}
+			}
+		})
+	}
+
+	for i := range 100 {
+		_, err := store.Update(func(s *Snapshot) (*Snapshot, error) {
+			return s.Set("_nameless.1d4.8d36.7040", "loan", NewIntAttribute(int64(i)))
+		})
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	wg.Wait()
+
+	if got := writeTestUnits(t, s.Units()); got != before {
+		t.Errorf("readers changed the first version:\n%s", got)
+	}
+	bank, _ := store.Load().Unit("_nameless.1d4.8d36.7040")
+	if money, _ := GetAs[int64](bank.Attrs, "money_account"); money != 1523644 {
+		t.Errorf("money_account = %d, want 1523644", money)
+	}
+	if bank.Attrs.Has("seen") {
+		t.Error("readers changed the current version")
+	}
+}
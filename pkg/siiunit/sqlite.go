@@ -0,0 +1,312 @@
+package siiunit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrSQLNotEmpty = errors.New("database already holds an exported save")
+
+// The SQL export writes a save into SQLite tables, the database is opened by the caller
+// with the driver of their choice:
+//
+//	sii_saves         id, name, exported_at
+//	sii_units         save_id, id, utype
+//	<utype>           save_id, id, one column per attribute
+//	<utype>__<key>    save_id, unit_id, idx, value for every element of an array
+//
+// Columns are typed from the attribute types, tuples and placements are split like
+// key_x, key_y, key_z. Keys holding different types in different units get untyped
+// BLOB columns. Attributes named like the save_id and id key columns are stored in
+// attr_save_id and attr_id. Pointer columns hold the ID of the unit they point at,
+// join them on sii_units with the same save_id to get to the unit.
+
+type SQLOption func(*sqlOptions) error
+
+type sqlOptions struct {
+	name   string
+	time   time.Time
+	append bool
+}
+
+// OptSQLSave sets the name and time the save is recorded with, by default "save" and
+// the time of the export
+func OptSQLSave(name string, at time.Time) SQLOption {
+	return func(so *sqlOptions) error {
+		if name == "" {
+			return fmt.Errorf("OptSQLSave: empty save name")
+		}
+		so.name, so.time = name, at
+		return nil
+	}
+}
+
+// OptSQLAppend adds the save to a database holding earlier saves, tables get the
+// columns they miss. Without it the export refuses a database that already holds one.
+func OptSQLAppend() SQLOption {
+	return func(so *sqlOptions) error {
+		so.append = true
+		return nil
+	}
+}
+
+// sqlTable is a table being exported, keyed by save_id and key
+type sqlTable struct {
+	name    string
+	key     []string // Primary key columns after save_id
+	columns []string // Value columns in first seen order
+	types   map[string]string
+	rows    []map[string]any
+}
+
+func newSQLTable(name string, key ...string) *sqlTable {
+	return &sqlTable{name: name, key: key, types: make(map[string]string)}
+}
+
+// set sets a column of a row and widens the column type to fit the value
+func (t *sqlTable) set(row map[string]any, column string, value any) {
+	row[column] = value
+
+	typ := sqlType(value)
+	prev, ok := t.types[column]
+	switch {
+	case !ok:
+		t.columns = append(t.columns, column)
+		t.types[column] = typ
+	case prev == typ:
+	case prev != "TEXT" && prev != "BLOB" && typ != "TEXT":
+		t.types[column] = "REAL"
+	default:
+		t.types[column] = "BLOB"
+	}
+}
+
+// sqlType returns the SQLite column type of a flattened value
+func sqlType(value any) string {
+	switch value.(type) {
+	case string:
+		return "TEXT"
+	case float64:
+		return "REAL"
+	default:
+		return "INTEGER"
+	}
+}
+
+// ExportSQLite writes units into the SQLite database as one save and returns its ID
+// in sii_saves. The export runs in a single transaction.
+func ExportSQLite(ctx context.Context, db *sql.DB, units []Unit, opts ...SQLOption) (int64, error) {
+	options := &sqlOptions{name: "save", time: time.Now()}
+	for _, opt := range opts {
+		if err := opt(options); err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidOption, err)
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	saveID, err := exportSQLite(ctx, tx, units, options)
+	if err != nil {
+		return 0, err
+	}
+	return saveID, tx.Commit()
+}
+
+func exportSQLite(ctx context.Context, tx *sql.Tx, units []Unit, options *sqlOptions) (int64, error) {
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS "sii_saves" `+
+		`("id" INTEGER PRIMARY KEY, "name" TEXT NOT NULL, "exported_at" TEXT NOT NULL)`); err != nil {
+		return 0, err
+	}
+
+	if !options.append {
+		var count int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM "sii_saves"`).Scan(&count); err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			return 0, ErrSQLNotEmpty
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO "sii_saves" ("name", "exported_at") VALUES (?, ?)`,
+		options.name, options.time.UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	saveID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, table := range sqlTables(units) {
+		if err := createSQLTable(ctx, tx, table); err != nil {
+			return 0, err
+		}
+		if err := insertSQLRows(ctx, tx, table, saveID); err != nil {
+			return 0, err
+		}
+	}
+
+	return saveID, nil
+}
+
+// sqlTables sorts the units into the tables of their types and arrays
+func sqlTables(units []Unit) []*sqlTable {
+	unitsTable := newSQLTable("sii_units", "id")
+	tables := []*sqlTable{unitsTable}
+	byName := make(map[string]*sqlTable)
+
+	byType := make(map[string][]Unit)
+	var types []string
+	for _, unit := range units {
+		if _, ok := byType[unit.Utype]; !ok {
+			types = append(types, unit.Utype)
+		}
+		byType[unit.Utype] = append(byType[unit.Utype], unit)
+
+		row := map[string]any{"id": unit.ID}
+		unitsTable.rows = append(unitsTable.rows, row)
+		unitsTable.set(row, "utype", unit.Utype)
+	}
+
+	for _, utype := range types {
+		arrays := arrayKeys(byType[utype])
+		keys := attributeKeys(byType[utype])
+
+		table := newSQLTable(utype, "id")
+		tables = append(tables, table)
+
+		for _, unit := range byType[utype] {
+			row := map[string]any{"id": unit.ID}
+			table.rows = append(table.rows, row)
+
+			for key, attr := range unit.Attrs.All() {
+				if !arrays[key] {
+					flattenAttribute(key, &attr, func(column string, value any) {
+						table.set(row, attrColumn(column, keys, "save_id", "id"), value)
+					})
+					continue
+				}
+				if attr.Atype != AttributeTypeArray {
+					continue
+				}
+
+				name := utype + "__" + key
+				child, ok := byName[name]
+				if !ok {
+					child = newSQLTable(name, "unit_id", "idx")
+					byName[name] = child
+					tables = append(tables, child)
+				}
+
+				for i := range attr.arrayVals {
+					elemRow := map[string]any{"unit_id": unit.ID, "idx": int64(i)}
+					child.rows = append(child.rows, elemRow)
+					flattenAttribute("value", &attr.arrayVals[i], func(column string, value any) {
+						child.set(elemRow, column, value)
+					})
+				}
+			}
+		}
+	}
+
+	return tables
+}
+
+// createSQLTable creates the table if needed and adds the columns an existing table misses
+func createSQLTable(ctx context.Context, tx *sql.Tx, table *sqlTable) error {
+	defs := []string{`"save_id" INTEGER NOT NULL REFERENCES "sii_saves" ("id")`}
+	primary := []string{quoteSQL("save_id")}
+	for _, column := range table.key {
+		defs = append(defs, quoteSQL(column)+" "+keyType(column)+" NOT NULL")
+		primary = append(primary, quoteSQL(column))
+	}
+	for _, column := range table.columns {
+		defs = append(defs, quoteSQL(column)+" "+table.types[column])
+	}
+	defs = append(defs, "PRIMARY KEY ("+strings.Join(primary, ", ")+")")
+
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteSQL(table.name), strings.Join(defs, ", "))
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table.name, err)
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table.name)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, column := range table.columns {
+		if existing[column] {
+			continue
+		}
+		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteSQL(table.name), quoteSQL(column), table.types[column])
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", table.name, column, err)
+		}
+	}
+	return nil
+}
+
+func keyType(column string) string {
+	if column == "idx" {
+		return "INTEGER"
+	}
+	return "TEXT"
+}
+
+// insertSQLRows inserts the rows of the table for the save
+func insertSQLRows(ctx context.Context, tx *sql.Tx, table *sqlTable, saveID int64) error {
+	columns := slices.Concat([]string{"save_id"}, table.key, table.columns)
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteSQL(column)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteSQL(table.name), strings.Join(quoted, ", "), placeholders))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]any, len(columns))
+	for _, row := range table.rows {
+		args[0] = saveID
+		for i, column := range columns[1:] {
+			args[i+1] = row[column]
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("failed to insert into %s: %w", table.name, err)
+		}
+	}
+	return nil
+}
+
+// quoteSQL quotes an SQL identifier
+func quoteSQL(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
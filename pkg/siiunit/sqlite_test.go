@@ -0,0 +1,130 @@
+package siiunit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The statements run against SQLite are tested in the sqlitetest module, see
+// internal/sqlitetest. These tests cover the tables the units are sorted into.
+
+// sqlTestTable returns the table with the given name
+func sqlTestTable(t *testing.T, tables []*sqlTable, name string) *sqlTable {
+	t.Helper()
+
+	i := slices.IndexFunc(tables, func(table *sqlTable) bool { return table.name == name })
+	if i < 0 {
+		t.Fatalf("sqlTables() has no table %s", name)
+	}
+	return tables[i]
+}
+
+// TestSQLTables tests the tables, columns and rows a save is exported to
+func TestSQLTables(t *testing.T) {
+	tables := sqlTables(parseTestSave(t))
+
+	wantColumns := map[string]string{
+		"sii_units":            "id utype",
+		"economy":              "id bank player game_time",
+		"economy__companies":   "unit_id idx value",
+		"bank":                 "id money_account coinsurance_fixed",
+		"player":               "id hq_city trailer_placement_x trailer_placement_y trailer_placement_z trailer_placement_rw trailer_placement_rx trailer_placement_ry trailer_placement_rz assigned_truck",
+		"company":              "id permanent_data",
+		"company__job_offer":   "unit_id idx value",
+		"job_offer_data":       "id target expiration_time",
+		"vehicle":              "id odometer",
+		"vehicle__accessories": "unit_id idx value",
+		"vehicle_accessory":    "id data_path",
+	}
+	if len(tables) != len(wantColumns) {
+		t.Errorf("sqlTables() = %d tables, want %d", len(tables), len(wantColumns))
+	}
+	for name, want := range wantColumns {
+		table := sqlTestTable(t, tables, name)
+		if got := strings.Join(slices.Concat(table.key, table.columns), " "); got != want {
+			t.Errorf("table %s columns = %s, want %s", name, got, want)
+		}
+	}
+
+	if got := len(sqlTestTable(t, tables, "sii_units").rows); got != 9 {
+		t.Errorf("sii_units has %d rows, want 9", got)
+	}
+
+	player := sqlTestTable(t, tables, "player").rows[0]
+	if player["trailer_placement_rw"] != 0.9998 || player["assigned_truck"] != "_nameless.1d4.8d36.7060" {
+		t.Errorf("player row = %v", player)
+	}
+	bank := sqlTestTable(t, tables, "bank")
+	if row := bank.rows[0]; row["coinsurance_fixed"] != 1.0 || row["money_account"] != int64(1523644) {
+		t.Errorf("bank row = %v", row)
+	}
+	if bank.types["money_account"] != "INTEGER" || bank.types["coinsurance_fixed"] != "REAL" {
+		t.Errorf("bank types = %v", bank.types)
+	}
+
+	// The volvo company has an empty job_offer array, written as job_offer: 0
+	offers := sqlTestTable(t, tables, "company__job_offer").rows
+	if len(offers) != 1 || offers[0]["unit_id"] != "company.volatile.scania_fac.paris" || offers[0]["value"] != "_nameless.1d4.8d36.7070" {
+		t.Errorf("company__job_offer rows = %v", offers)
+	}
+	accessories := sqlTestTable(t, tables, "vehicle__accessories").rows
+	if len(accessories) != 2 || accessories[1]["idx"] != int64(1) || accessories[1]["value"] != "_nameless.1d4.8d36.7090" {
+		t.Errorf("vehicle__accessories rows = %v", accessories)
+	}
+}
+
+// TestSQLTablesKeyColumns tests attributes named like key columns don't overwrite them
+func TestSQLTablesKeyColumns(t *testing.T) {
+	attrs := NewAttributes()
+	attrs.Set("id", NewIntAttribute(7))
+	attrs.Set("save_id", NewStringAttribute("autosave"))
+	attrs.Set("attr_id", NewBoolAttribute(true))
+	attrs.Set("unit_id", NewIntAttribute(1))
+
+	tables := sqlTables([]Unit{{Utype: "bank", ID: "bank.player", Attrs: attrs}})
+	bank := sqlTestTable(t, tables, "bank")
+
+	if got, want := strings.Join(slices.Concat(bank.key, bank.columns), " "), "id attr_attr_id attr_save_id attr_id unit_id"; got != want {
+		t.Errorf("bank columns = %s, want %s", got, want)
+	}
+	row := bank.rows[0]
+	if row["id"] != "bank.player" || row["attr_attr_id"] != int64(7) || row["attr_save_id"] != "autosave" || row["attr_id"] != true {
+		t.Errorf("bank row = %v", row)
+	}
+}
+
+// TestSQLTableTypes tests column types follow the attribute types and widen on conflicts
+func TestSQLTableTypes(t *testing.T) {
+	tests := []struct {
+		values []any
+		want   string
+	}{
+		{values: []any{"a", "b"}, want: "TEXT"},
+		{values: []any{int64(1), true}, want: "INTEGER"},
+		{values: []any{int64(1), 2.5}, want: "REAL"},
+		{values: []any{2.5, int64(1)}, want: "REAL"},
+		{values: []any{int64(1), "a"}, want: "BLOB"},
+		{values: []any{"a", 2.5, int64(1)}, want: "BLOB"},
+	}
+
+	for _, tt := range tests {
+		table := newSQLTable("t", "id")
+		for _, v := range tt.values {
+			table.set(map[string]any{}, "c", v)
+		}
+		if got := table.types["c"]; got != tt.want {
+			t.Errorf("types of %v = %s, want %s", tt.values, got, tt.want)
+		}
+	}
+}
+
+// TestExportSQLiteOptions tests invalid options are rejected before the database is used
+func TestExportSQLiteOptions(t *testing.T) {
+	if _, err := ExportSQLite(context.Background(), nil, nil, OptSQLSave("", time.Now())); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("ExportSQLite() with empty name error = %v, want %v", err, ErrInvalidOption)
+	}
+}
@@ -0,0 +1,127 @@
+package siiunit
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// fieldInfo describes a struct field with a `sii` tag
+type fieldInfo struct {
+	index []int
+	name  string // Attribute key
+	id    bool   // Field holds the unit ID
+	utype bool   // Field holds the unit type
+
+	lenient bool // Unmarshal coerces 0/1 to bools
+
+	// Marshal hints
+	hint     string // Attribute type name or "token" to force the attribute type
+	hexFloat bool   // Write floats in the &xxxxxxxx form
+}
+
+// structFields returns the tagged fields of t, including the ones of embedded structs.
+//
+// Supported tags:
+//
+//	`sii:"money_account"` maps the field to the money_account attribute
+//	`sii:",id"`           maps the field to the unit ID
+//	`sii:"vehicle,utype"` maps the field to the unit type, Marshal uses vehicle if it's empty
+//	`sii:"-"`             ignores the field
+//	`sii:",hexfloat"`     maps the field to its snake_case name, fuel_relative for FuelRelative
+//
+// Unmarshal takes the `sii:"enabled,lenient"` option to coerce 0/1 to bools.
+// Marshal also takes a type hint such as `sii:"pos,float3"` or `sii:"cargo,token"`
+// and the `sii:"price,hexfloat"` option. Fields without a tag are ignored.
+func structFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+
+	for i := range t.NumField() {
+		sf := t.Field(i)
+
+		tag, tagged := sf.Tag.Lookup("sii")
+		if tag == "-" {
+			continue
+		}
+
+		if sf.Anonymous && !tagged {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, f := range structFields(ft) {
+					f.index = append([]int{i}, f.index...)
+					fields = append(fields, f)
+				}
+			}
+			continue
+		}
+
+		if !tagged || !sf.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		f := fieldInfo{index: []int{i}, name: name}
+
+		for opt := range strings.SplitSeq(opts, ",") {
+			switch opt {
+			case "id":
+				f.id = true
+			case "utype":
+				f.utype = true
+			case "lenient":
+				f.lenient = true
+			case "hexfloat":
+				f.hexFloat = true
+			case "token", "ptr":
+				f.hint = "token"
+			case "":
+			default:
+				f.hint = opt
+			}
+		}
+
+		if f.name == "" && !f.id && !f.utype {
+			f.name = snakeCase(sf.Name)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// snakeCase turns a Go field name into an attribute key, HQCity becomes hq_city
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := rune(name[i-1])
+			next := rune(0)
+			if i+1 < len(name) {
+				next = rune(name[i+1])
+			}
+			if !unicode.IsUpper(prev) && prev != '_' || unicode.IsUpper(prev) && unicode.IsLower(next) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex but allocates nil embedded pointers on the way
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
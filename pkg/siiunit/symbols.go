@@ -0,0 +1,73 @@
+package siiunit
+
+import (
+	"strings"
+	"sync"
+)
+
+// SymbolTable interns strings that repeat a lot in save files (unit types,
+// attribute keys and tokens) so every occurrence shares the same memory.
+// It is safe for concurrent use and can be shared across parses with OptSymbolTable.
+type SymbolTable struct {
+	mu      sync.RWMutex
+	symbols map[string]string
+}
+
+// NewSymbolTable creates an empty symbol table
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		symbols: make(map[string]string),
+	}
+}
+
+// Intern returns the shared copy of s, adding it to the table if it is new.
+// A nil table returns s unchanged.
+func (st *SymbolTable) Intern(s string) string {
+	if st == nil {
+		return s
+	}
+
+	st.mu.RLock()
+	sym, ok := st.symbols[s]
+	st.mu.RUnlock()
+	if ok {
+		return sym
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if sym, ok := st.symbols[s]; ok {
+		return sym
+	}
+
+	// Clone so the table doesn't keep the whole scanned line alive
+	sym = strings.Clone(s)
+	st.symbols[sym] = sym
+	return sym
+}
+
+// Len returns the number of interned strings
+func (st *SymbolTable) Len() int {
+	if st == nil {
+		return 0
+	}
+
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	return len(st.symbols)
+}
+
+// internAttribute interns the value of token attributes. Quoted strings and
+// nameless pointers are left alone since they rarely repeat.
+func (st *SymbolTable) internAttribute(attr *Attribute) {
+	if st == nil || attr.Atype != AttributeTypeString || attr.quoted {
+		return
+	}
+
+	if strings.HasPrefix(attr.stringVal, namelessPrefix) {
+		return
+	}
+
+	attr.stringVal = st.Intern(attr.stringVal)
+}
@@ -0,0 +1,351 @@
+package siiunit
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrTxDone = errors.New("transaction already committed or rolled back")
+
+// ChangeKind is the kind of edit a Change records
+type ChangeKind int
+
+const (
+	ChangeSet ChangeKind = iota
+	ChangeDelete
+	ChangeInsert
+	ChangeRemove
+	ChangeCreateUnit
+	ChangeDeleteUnit
+)
+
+var changeKindNames = map[ChangeKind]string{
+	ChangeSet:        "set",
+	ChangeDelete:     "delete",
+	ChangeInsert:     "insert",
+	ChangeRemove:     "remove",
+	ChangeCreateUnit: "create unit",
+	ChangeDeleteUnit: "delete unit",
+}
+
+func (k ChangeKind) String() string {
+	return changeKindNames[k]
+}
+
+// Change is one edit made through a Tx
+type Change struct {
+	Kind   ChangeKind
+	UnitID string
+	Utype  string
+	Key    string     // Empty for unit changes
+	Index  int        // Array index of inserts and removes
+	Old    *Attribute // Nil if the attribute didn't exist
+	New    *Attribute // Nil if the attribute was deleted
+
+	apply  func()
+	revert func()
+}
+
+// String returns a one line summary of the change
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeSet:
+		if c.Old == nil {
+			return fmt.Sprintf("set %s.%s: %s", c.UnitID, c.Key, c.New.Printable())
+		}
+		return fmt.Sprintf("set %s.%s: %s -> %s", c.UnitID, c.Key, c.Old.Printable(), c.New.Printable())
+	case ChangeDelete:
+		return fmt.Sprintf("delete %s.%s", c.UnitID, c.Key)
+	case ChangeInsert:
+		return fmt.Sprintf("insert %s.%s[%d]: %s", c.UnitID, c.Key, c.Index, c.New.Printable())
+	case ChangeRemove:
+		return fmt.Sprintf("remove %s.%s[%d]", c.UnitID, c.Key, c.Index)
+	default:
+		return fmt.Sprintf("%s %s : %s", c.Kind, c.Utype, c.UnitID)
+	}
+}
+
+// Tx records edits to a document so they can be rolled back, or undone and redone
+// after Commit. Edits are applied to the document right away. Edits made directly on
+// the document or its units while a Tx is open aren't recorded and can confuse undo.
+type Tx struct {
+	doc     *Document
+	changes []Change
+	done    bool
+}
+
+// Begin starts a transaction on the document
+func (d *Document) Begin() *Tx {
+	return &Tx{doc: d}
+}
+
+// Changes returns the edits recorded so far in the order they were made
+func (tx *Tx) Changes() []Change {
+	return slices.Clone(tx.changes)
+}
+
+// Commit ends the transaction and adds it to the undo history of the document
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	if len(tx.changes) > 0 {
+		tx.doc.undo = append(tx.doc.undo, tx.changes)
+		tx.doc.redo = nil
+	}
+	return nil
+}
+
+// Rollback ends the transaction and reverts all of its edits
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+
+	revertChanges(tx.changes)
+	return nil
+}
+
+// Undo reverts the last committed transaction, it reports false if there is none
+func (d *Document) Undo() bool {
+	if len(d.undo) == 0 {
+		return false
+	}
+
+	changes := d.undo[len(d.undo)-1]
+	d.undo = d.undo[:len(d.undo)-1]
+
+	revertChanges(changes)
+	d.redo = append(d.redo, changes)
+	return true
+}
+
+// Redo applies the last undone transaction again, it reports false if there is none
+func (d *Document) Redo() bool {
+	if len(d.redo) == 0 {
+		return false
+	}
+
+	changes := d.redo[len(d.redo)-1]
+	d.redo = d.redo[:len(d.redo)-1]
+
+	for _, c := range changes {
+		c.apply()
+	}
+	d.undo = append(d.undo, changes)
+	return true
+}
+
+func revertChanges(changes []Change) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		changes[i].revert()
+	}
+}
+
+// record applies the change and adds it to the log
+func (tx *Tx) record(c Change) {
+	c.apply()
+	tx.changes = append(tx.changes, c)
+}
+
+// unit returns the unit with the given ID for an edit
+func (tx *Tx) unit(id string) (Unit, error) {
+	if tx.done {
+		return Unit{}, ErrTxDone
+	}
+
+	unit, ok := tx.doc.Unit(id)
+	if !ok {
+		return Unit{}, fmt.Errorf("%w: %s", ErrUnitNotFound, id)
+	}
+	return unit, nil
+}
+
+// Set stores attr under key in the unit with the given ID
+func (tx *Tx) Set(id, key string, attr Attribute) error {
+	unit, err := tx.unit(id)
+	if err != nil {
+		return err
+	}
+	if err := attr.validate(); err != nil {
+		return fmt.Errorf("failed to set attribute %s: %w", key, err)
+	}
+
+	attrs := unit.Attrs
+	newAttr := attr.clone()
+	c := Change{Kind: ChangeSet, UnitID: id, Utype: unit.Utype, Key: key, New: &newAttr}
+
+	if old, ok := attrs.lookup(key); ok {
+		oldAttr := old.clone()
+		c.Old = &oldAttr
+		c.revert = func() { attrs.set(key, ptrTo(oldAttr.clone())) }
+	} else {
+		c.revert = func() { attrs.Delete(key) }
+	}
+	c.apply = func() { attrs.set(key, ptrTo(newAttr.clone())) }
+
+	tx.record(c)
+	return nil
+}
+
+// Delete removes the attribute stored under key from the unit with the given ID
+func (tx *Tx) Delete(id, key string) error {
+	unit, err := tx.unit(id)
+	if err != nil {
+		return err
+	}
+
+	attrs := unit.Attrs
+	old, ok := attrs.lookup(key)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrAttributeNotFound, key)
+	}
+
+	oldAttr := old.clone()
+	pos := slices.Index(attrs.keys, key)
+	comment := attrs.Comment(key)
+	tx.record(Change{
+		Kind:   ChangeDelete,
+		UnitID: id,
+		Utype:  unit.Utype,
+		Key:    key,
+		Old:    &oldAttr,
+		apply:  func() { attrs.Delete(key) },
+		revert: func() {
+			attrs.insertKey(pos, key, ptrTo(oldAttr.clone()))
+			attrs.SetComment(key, comment)
+		},
+	})
+	return nil
+}
+
+// InsertAt inserts elem into the array stored under key before index
+func (tx *Tx) InsertAt(id, key string, index int, elem Attribute) error {
+	unit, err := tx.unit(id)
+	if err != nil {
+		return err
+	}
+
+	// Check the insert on a copy so a failed one isn't recorded
+	arr, err := unit.Attrs.array(key)
+	if err != nil {
+		return err
+	}
+	check := arr.clone()
+	if err := check.InsertAt(index, elem); err != nil {
+		return err
+	}
+
+	attrs := unit.Attrs
+	tx.record(Change{
+		Kind:   ChangeInsert,
+		UnitID: id,
+		Utype:  unit.Utype,
+		Key:    key,
+		Index:  index,
+		New:    &elem,
+		apply:  func() { attrs.InsertAt(key, index, elem) },
+		revert: func() { attrs.RemoveAt(key, index) },
+	})
+	return nil
+}
+
+// RemoveAt removes the element at index from the array stored under key
+func (tx *Tx) RemoveAt(id, key string, index int) error {
+	unit, err := tx.unit(id)
+	if err != nil {
+		return err
+	}
+
+	arr, err := unit.Attrs.array(key)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(arr.arrayVals) {
+		return fmt.Errorf("%w: %d", ErrIndexOutOfRange, index)
+	}
+
+	attrs := unit.Attrs
+	old := arr.arrayVals[index]
+	tx.record(Change{
+		Kind:   ChangeRemove,
+		UnitID: id,
+		Utype:  unit.Utype,
+		Key:    key,
+		Index:  index,
+		Old:    &old,
+		apply:  func() { attrs.RemoveAt(key, index) },
+		revert: func() { attrs.InsertAt(key, index, old) },
+	})
+	return nil
+}
+
+// Create adds a new empty unit with a fresh nameless ID
+func (tx *Tx) Create(utype string) (Unit, error) {
+	if tx.done {
+		return Unit{}, ErrTxDone
+	}
+
+	d := tx.doc
+	unit := Unit{
+		Utype: utype,
+		ID:    d.nameless.Next(),
+		Attrs: newAttributes(),
+	}
+
+	tx.record(Change{
+		Kind:   ChangeCreateUnit,
+		UnitID: unit.ID,
+		Utype:  utype,
+		apply:  func() { d.insert(len(d.units), unit) },
+		revert: func() { d.Delete(unit.ID, RefKeep) },
+	})
+	return unit, nil
+}
+
+// DeleteUnit removes the unit with the given ID and fixes up pointers to it
+func (tx *Tx) DeleteUnit(id string, fixup RefFixup) error {
+	unit, err := tx.unit(id)
+	if err != nil {
+		return err
+	}
+
+	d := tx.doc
+	pos := d.index[id]
+
+	// Keep copies of the units pointing to the deleted one so the fixups can be reverted
+	referrers := make(map[*Attributes]*Attributes)
+	if fixup != RefKeep {
+		for _, u := range d.units {
+			for _, attr := range u.Attrs.All() {
+				forEachToken(&attr, func(token string) {
+					if token == id {
+						referrers[u.Attrs] = u.Attrs.Clone()
+					}
+				})
+			}
+		}
+	}
+
+	tx.record(Change{
+		Kind:   ChangeDeleteUnit,
+		UnitID: id,
+		Utype:  unit.Utype,
+		apply:  func() { d.Delete(id, fixup) },
+		revert: func() {
+			d.insert(pos, unit)
+			for attrs, saved := range referrers {
+				attrs.restore(saved)
+			}
+		},
+	})
+	return nil
+}
+
+func ptrTo(attr Attribute) *Attribute {
+	return &attr
+}
@@ -0,0 +1,152 @@
+package siiunit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func writeTestDocument(t *testing.T, doc *Document) string {
+	t.Helper()
+
+	var sb strings.Builder
+	if err := WriteUnits(&sb, doc.Units()); err != nil {
+		t.Fatalf("WriteUnits() error = %v", err)
+	}
+	return sb.String()
+}
+
+// editTestDocument makes one edit of every kind
+func editTestDocument(t *testing.T, tx *Tx) {
+	t.Helper()
+
+	steps := []error{
+		tx.Set("_nameless.1d4.8d36.7050", "hq_city", NewTokenAttribute("paris")),
+		tx.Set("_nameless.1d4.8d36.7040", "loan_limit", NewIntAttribute(5000)),
+		tx.Delete("_nameless.1d4.8d36.7040", "money_account"),
+		tx.InsertAt("_nameless.1d4.8d36.7030", "companies", 0, NewTokenAttribute("company.volatile.daf.paris")),
+		tx.RemoveAt("_nameless.1d4.8d36.7060", "accessories", 1),
+		tx.DeleteUnit("_nameless.1d4.8d36.7070", RefDrop),
+	}
+	unit, err := tx.Create("vehicle_accessory")
+	steps = append(steps, err, tx.Set(unit.ID, "data_path", NewStringAttribute("/def/vehicle/truck/volvo.fh16_2012/cabin/globetrotter.sii")))
+
+	for i, err := range steps {
+		if err != nil {
+			t.Fatalf("edit %d error = %v", i, err)
+		}
+	}
+}
+
+// TestTxRollback tests a rolled back transaction leaves the document as it was
+func TestTxRollback(t *testing.T) {
+	doc := parseTestDocument(t)
+
+	// The comment of the deleted attribute comes back with it
+	bank, _ := doc.Unit("_nameless.1d4.8d36.7040")
+	if err := bank.Attrs.SetComment("money_account", "In euros"); err != nil {
+		t.Fatalf("SetComment() error = %v", err)
+	}
+	before := writeTestDocument(t, doc)
+
+	tx := doc.Begin()
+	editTestDocument(t, tx)
+
+	if writeTestDocument(t, doc) == before {
+		t.Fatal("edits are not applied before commit")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if got := writeTestDocument(t, doc); got != before {
+		t.Errorf("Rollback() left\n%s\nwant\n%s", got, before)
+	}
+
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Commit() after Rollback error = %v, want %v", err, ErrTxDone)
+	}
+	if err := tx.Set("_nameless.1d4.8d36.7050", "hq_city", NewTokenAttribute("paris")); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Set() after Rollback error = %v, want %v", err, ErrTxDone)
+	}
+	if doc.Undo() {
+		t.Error("Undo() after Rollback should have nothing to undo")
+	}
+}
+
+// TestTxUndoRedo tests committed transactions can be undone and redone
+func TestTxUndoRedo(t *testing.T) {
+	doc := parseTestDocument(t)
+	before := writeTestDocument(t, doc)
+
+	tx := doc.Begin()
+	editTestDocument(t, tx)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	after := writeTestDocument(t, doc)
+
+	for range 2 {
+		if !doc.Undo() {
+			t.Fatal("Undo() = false, want true")
+		}
+		if got := writeTestDocument(t, doc); got != before {
+			t.Errorf("Undo() left\n%s\nwant\n%s", got, before)
+		}
+
+		if !doc.Redo() {
+			t.Fatal("Redo() = false, want true")
+		}
+		if got := writeTestDocument(t, doc); got != after {
+			t.Errorf("Redo() left\n%s\nwant\n%s", got, after)
+		}
+	}
+
+	if doc.Redo() {
+		t.Error("Redo() with nothing undone = true, want false")
+	}
+
+	// The dropped job offer pointer comes back on undo
+	doc.Undo()
+	company, _ := doc.Unit("company.volatile.scania_fac.paris")
+	if offers, _ := GetAs[[]string](company.Attrs, "job_offer"); len(offers) != 1 {
+		t.Errorf("job_offer after Undo = %v, want one offer", offers)
+	}
+}
+
+// TestTxChanges tests the change list summary
+func TestTxChanges(t *testing.T) {
+	doc := parseTestDocument(t)
+
+	tx := doc.Begin()
+	editTestDocument(t, tx)
+
+	want := []string{
+		"set _nameless.1d4.8d36.7050.hq_city: berlin -> paris",
+		"set _nameless.1d4.8d36.7040.loan_limit: 5000",
+		"delete _nameless.1d4.8d36.7040.money_account",
+		"insert _nameless.1d4.8d36.7030.companies[0]: company.volatile.daf.paris",
+		"remove _nameless.1d4.8d36.7060.accessories[1]",
+		"delete unit job_offer_data : _nameless.1d4.8d36.7070",
+		"create unit vehicle_accessory : _nameless.1d4.8d36.70a0",
+		"set _nameless.1d4.8d36.70a0.data_path: /def/vehicle/truck/volvo.fh16_2012/cabin/globetrotter.sii",
+	}
+
+	changes := tx.Changes()
+	if len(changes) != len(want) {
+		t.Fatalf("Changes() = %d changes, want %d", len(changes), len(want))
+	}
+	for i, c := range changes {
+		if got := c.String(); got != want[i] {
+			t.Errorf("Changes()[%d] = %v, want %v", i, got, want[i])
+		}
+	}
+
+	// Failed edits are not recorded
+	tx.InsertAt("_nameless.1d4.8d36.7030", "companies", 0, NewIntAttribute(1))
+	tx.RemoveAt("_nameless.1d4.8d36.7060", "accessories", 5)
+	tx.Delete("_nameless.1d4.8d36.7040", "missing")
+	if len(tx.Changes()) != len(want) {
+		t.Errorf("failed edits were recorded: %v", tx.Changes()[len(want):])
+	}
+}
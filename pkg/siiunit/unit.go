@@ -2,10 +2,29 @@ package siiunit
 
 import "strings"
 
+// namelessPrefix starts the ID of every unit without a name of its own
+const namelessPrefix = "_nameless."
+
+// Unit is a single unit block of a SII file.
+//
+// Attrs is a pointer so edits made through a Document, a Tx or any copy of the unit are
+// seen by every copy. Build units with NewAttributes or &Attributes{}, a nil Attrs reads
+// as an empty set.
 type Unit struct {
-	Utype string
-	ID    string
-	Attrs Attributes
+	Utype   string
+	ID      string
+	Attrs   *Attributes
+	Comment string // Comment lines above the unit header, joined by newlines
+}
+
+// Clone returns a copy of the unit under a new ID that doesn't share attributes with u
+func (u Unit) Clone(id string) Unit {
+	return Unit{
+		Utype:   u.Utype,
+		ID:      id,
+		Attrs:   u.Attrs.Clone(),
+		Comment: u.Comment,
+	}
 }
 
 func (u Unit) String() string {
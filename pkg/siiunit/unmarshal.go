@@ -0,0 +1,373 @@
+package siiunit
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Placement holds the position and rotation quaternion (w, x, y, z) of a placement attribute
+type Placement struct {
+	Pos [3]float64
+	Rot [4]float64
+}
+
+var (
+	ErrInvalidTarget = errors.New("unmarshal target must be a non-nil pointer to a struct")
+	ErrUnresolved    = errors.New("pointer does not resolve to a unit")
+	ErrPointerCycle  = errors.New("pointer cycle while resolving nested units")
+)
+
+// UnmarshalTypeError describes an attribute that can't be stored in a Go value
+type UnmarshalTypeError struct {
+	Key   string       // Attribute key, array elements get an [index] suffix
+	Atype string       // Attribute type name
+	Type  reflect.Type // Go type of the target
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("cannot unmarshal %s attribute %s into Go value of type %s", e.Atype, e.Key, e.Type)
+}
+
+// Unwrap makes errors.Is(err, ErrInvalidType) work for type mismatches
+func (e *UnmarshalTypeError) Unwrap() error {
+	return ErrInvalidType
+}
+
+// UnitResolver looks up a unit by its ID
+type UnitResolver func(id string) (Unit, bool)
+
+// Unmarshal stores the attributes of unit in the struct pointed to by v, using the
+// `sii` struct tags to map attribute keys to fields (see structFields).
+//
+// Attributes map to Go values like this:
+//
+//	string, token, pointer  -> string
+//	int                     -> int, uint kinds
+//	float                   -> float32, float64
+//	bool                    -> bool
+//	float2/3/4, int2/3/4    -> arrays of the same length, or structs with that many numeric fields
+//	placement               -> Placement
+//	array                   -> slices of any of the above
+//	anything                -> Attribute
+//
+// Pointers to other units decode to their ID as a string. Use UnmarshalResolve to
+// decode them into nested structs instead. Missing attributes leave the field untouched.
+// Ints and int tuples decode into floats of the same shape when they convert exactly,
+// as whole floats are often written without a fraction. Fields tagged
+// `sii:"enabled,lenient"` also accept 0/1 for bools, the same as Attribute.AsBool.
+func Unmarshal(unit Unit, v any) error {
+	return UnmarshalResolve(unit, v, nil)
+}
+
+// UnmarshalResolve is Unmarshal but decodes pointer attributes into struct (or pointer to struct)
+// fields by looking up the referenced unit with resolve.
+func UnmarshalResolve(unit Unit, v any, resolve UnitResolver) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrInvalidTarget
+	}
+
+	d := &decoder{resolve: resolve, visiting: make(map[string]bool)}
+	return d.decodeUnit(unit, rv.Elem())
+}
+
+type decoder struct {
+	resolve  UnitResolver
+	visiting map[string]bool // Units currently being decoded, to catch cycles
+	lenient  bool            // Coerce 0/1 to bools, see Attribute.AsBool
+}
+
+func (d *decoder) decodeUnit(unit Unit, rv reflect.Value) error {
+	d.visiting[unit.ID] = true
+	defer delete(d.visiting, unit.ID)
+
+	for _, f := range structFields(rv.Type()) {
+		fv := fieldByIndex(rv, f.index)
+
+		switch {
+		case f.id:
+			if err := d.decodeString(unit.ID, ",id", fv); err != nil {
+				return err
+			}
+		case f.utype:
+			if err := d.decodeString(unit.Utype, ",utype", fv); err != nil {
+				return err
+			}
+		default:
+			attr, ok := unit.Attrs.lookup(f.name)
+			if !ok {
+				continue
+			}
+
+			lenient := d.lenient
+			d.lenient = lenient || f.lenient
+			err := d.decodeAttr(attr, f.name, fv)
+			d.lenient = lenient
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// decodeString stores a header string (ID or unit type) in a string field
+func (d *decoder) decodeString(s, key string, rv reflect.Value) error {
+	if rv.Kind() != reflect.String {
+		return &UnmarshalTypeError{Key: key, Atype: "string", Type: rv.Type()}
+	}
+	rv.SetString(s)
+	return nil
+}
+
+var (
+	attributeGoType = reflect.TypeFor[Attribute]()
+	placementGoType = reflect.TypeFor[Placement]()
+)
+
+// decodeAttr stores attr in rv, key is only used for error messages
+func (d *decoder) decodeAttr(attr *Attribute, key string, rv reflect.Value) error {
+	typeErr := &UnmarshalTypeError{Key: key, Atype: attr.TypeName(), Type: rv.Type()}
+
+	if rv.Type() == attributeGoType {
+		rv.Set(reflect.ValueOf(*attr))
+		return nil
+	}
+
+	if target, ok := coerceTarget(rv.Type()); ok && (d.lenient || (target != AttributeTypeBool && exactInFloat(attr))) {
+		if c, err := attr.coerce(target); err == nil {
+			attr = c
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer:
+		// A null pointer attribute or an unresolvable unit leaves the pointer nil
+		if isNullPointer(attr) {
+			rv.SetZero()
+			return nil
+		}
+
+		elem := reflect.New(rv.Type().Elem())
+		if err := d.decodeAttr(attr, key, elem.Elem()); err != nil {
+			if errors.Is(err, ErrUnresolved) {
+				rv.SetZero()
+				return nil
+			}
+			return err
+		}
+		rv.Set(elem)
+
+	case reflect.String:
+		if attr.Atype != AttributeTypeString {
+			return typeErr
+		}
+		rv.SetString(attr.stringVal)
+
+	case reflect.Bool:
+		if attr.Atype != AttributeTypeBool {
+			return typeErr
+		}
+		rv.SetBool(attr.boolVal)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if attr.Atype != AttributeTypeInt || rv.OverflowInt(attr.intVal) {
+			return typeErr
+		}
+		rv.SetInt(attr.intVal)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if attr.Atype != AttributeTypeInt || attr.intVal < 0 || rv.OverflowUint(uint64(attr.intVal)) {
+			return typeErr
+		}
+		rv.SetUint(uint64(attr.intVal))
+
+	case reflect.Float32, reflect.Float64:
+		if attr.Atype != AttributeTypeFloat {
+			return typeErr
+		}
+		rv.SetFloat(attr.floatVal)
+
+	case reflect.Array:
+		vals, ok := vectorValues(attr)
+		if !ok || len(vals) != rv.Len() {
+			return typeErr
+		}
+		for i, val := range vals {
+			if !setNumber(rv.Index(i), val) {
+				return typeErr
+			}
+		}
+
+	case reflect.Slice:
+		// An empty array is only its "key: 0" line, which parses as an int
+		if attr.Atype == AttributeTypeInt && attr.intVal == 0 {
+			rv.SetZero()
+			return nil
+		}
+
+		if attr.Atype != AttributeTypeArray {
+			return typeErr
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(attr.arrayVals), len(attr.arrayVals))
+		for i := range attr.arrayVals {
+			if err := d.decodeAttr(&attr.arrayVals[i], fmt.Sprintf("%s[%d]", key, i), slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+
+	case reflect.Struct:
+		return d.decodeStruct(attr, key, rv, typeErr)
+
+	default:
+		return typeErr
+	}
+
+	return nil
+}
+
+// decodeStruct stores a placement, a vector or a referenced unit in a struct value
+func (d *decoder) decodeStruct(attr *Attribute, key string, rv reflect.Value, typeErr error) error {
+	if rv.Type() == placementGoType {
+		if attr.Atype != AttributeTypePlacement {
+			return typeErr
+		}
+		rv.Set(reflect.ValueOf(Placement{Pos: attr.placementPos, Rot: attr.placementRot}))
+		return nil
+	}
+
+	// Vectors decode into user types such as struct{ X, Y, Z float64 }
+	if vals, ok := vectorValues(attr); ok {
+		if rv.NumField() != len(vals) {
+			return typeErr
+		}
+		for i, val := range vals {
+			if !rv.Type().Field(i).IsExported() || !setNumber(rv.Field(i), val) {
+				return typeErr
+			}
+		}
+		return nil
+	}
+
+	// Pointers decode into the struct of the unit they point to
+	if attr.Atype != AttributeTypeString || d.resolve == nil {
+		return typeErr
+	}
+
+	id := attr.stringVal
+	if d.visiting[id] {
+		return fmt.Errorf("%w: %s -> %s", ErrPointerCycle, key, id)
+	}
+
+	unit, ok := d.resolve(id)
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrUnresolved, key, id)
+	}
+
+	return d.decodeUnit(unit, rv)
+}
+
+// coerceTarget returns the attribute type a decode into t may coerce to
+func coerceTarget(t reflect.Type) (AttributeType, bool) {
+	isFloat := func(k reflect.Kind) bool { return k == reflect.Float32 || k == reflect.Float64 }
+
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return AttributeTypeFloat, true
+	case reflect.Bool:
+		return AttributeTypeBool, true
+	case reflect.Array:
+		if t.Len() >= 2 && t.Len() <= 4 && isFloat(t.Elem().Kind()) {
+			return floatVectorTypes[t.Len()], true
+		}
+	case reflect.Struct:
+		if t != placementGoType && t.NumField() >= 2 && t.NumField() <= 4 && isFloat(t.Field(0).Type.Kind()) {
+			return floatVectorTypes[t.NumField()], true
+		}
+	}
+
+	return 0, false
+}
+
+// exactInFloat reports if the ints of an int or int tuple attribute convert to float64
+// without rounding
+func exactInFloat(attr *Attribute) bool {
+	var ints []int64
+	switch attr.Atype {
+	case AttributeTypeInt:
+		ints = []int64{attr.intVal}
+	case AttributeTypeInt2:
+		ints = attr.int2Vals[:]
+	case AttributeTypeInt3:
+		ints = attr.int3Vals[:]
+	case AttributeTypeInt4:
+		ints = attr.int4Vals[:]
+	default:
+		return false
+	}
+
+	for _, n := range ints {
+		if n > 1<<53 || n < -(1<<53) {
+			return false
+		}
+	}
+	return true
+}
+
+// vectorValues returns the components of float2/3/4 and int2/3/4 attributes
+func vectorValues(attr *Attribute) ([]any, bool) {
+	switch attr.Atype {
+	case AttributeTypeFloat2:
+		return []any{attr.float2Vals[0], attr.float2Vals[1]}, true
+	case AttributeTypeFloat3:
+		return []any{attr.float3Vals[0], attr.float3Vals[1], attr.float3Vals[2]}, true
+	case AttributeTypeFloat4:
+		return []any{attr.float4Vals[0], attr.float4Vals[1], attr.float4Vals[2], attr.float4Vals[3]}, true
+	case AttributeTypeInt2:
+		return []any{attr.int2Vals[0], attr.int2Vals[1]}, true
+	case AttributeTypeInt3:
+		return []any{attr.int3Vals[0], attr.int3Vals[1], attr.int3Vals[2]}, true
+	case AttributeTypeInt4:
+		return []any{attr.int4Vals[0], attr.int4Vals[1], attr.int4Vals[2], attr.int4Vals[3]}, true
+	}
+	return nil, false
+}
+
+// setNumber stores a float64 or int64 vector component in a numeric value.
+// Floats only go into float kinds, ints only into int kinds.
+func setNumber(rv reflect.Value, val any) bool {
+	switch n := val.(type) {
+	case float64:
+		if rv.Kind() != reflect.Float32 && rv.Kind() != reflect.Float64 {
+			return false
+		}
+		rv.SetFloat(n)
+	case int64:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if rv.OverflowInt(n) {
+				return false
+			}
+			rv.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n < 0 || rv.OverflowUint(uint64(n)) {
+				return false
+			}
+			rv.SetUint(uint64(n))
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// isNullPointer reports if the attribute is the null pointer token
+func isNullPointer(attr *Attribute) bool {
+	return attr.Atype == AttributeTypeString && !attr.quoted && attr.stringVal == "null"
+}
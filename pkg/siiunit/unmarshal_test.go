@@ -0,0 +1,196 @@
+package siiunit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testVec3 struct {
+	X, Y, Z float64
+}
+
+type testAccessory struct {
+	ID       string `sii:",id"`
+	Utype    string `sii:"vehicle_accessory,utype"`
+	DataPath string `sii:"data_path"`
+}
+
+type testVehicle struct {
+	ID          string           `sii:",id"`
+	Utype       string           `sii:",utype"`
+	Odometer    uint32           `sii:"odometer"`
+	Accessories []*testAccessory `sii:"accessories"`
+}
+
+type testPlayer struct {
+	HqCity           string       `sii:"hq_city"`
+	TrailerPlacement Placement    `sii:"trailer_placement"`
+	AssignedTruck    string       `sii:"assigned_truck"`
+	Truck            *testVehicle `sii:"assigned_truck"`
+	Ignored          string       `sii:"-"`
+}
+
+func parseTestSave(t *testing.T) []Unit {
+	t.Helper()
+
+	units, err := ParseAllUnits(strings.NewReader(testSave))
+	if err != nil {
+		t.Fatalf("ParseAllUnits() error = %v", err)
+	}
+	return units
+}
+
+func testResolver(units []Unit) UnitResolver {
+	return func(id string) (Unit, bool) {
+		for _, u := range units {
+			if u.ID == id {
+				return u, true
+			}
+		}
+		return Unit{}, false
+	}
+}
+
+// TestUnmarshal tests decoding units into tagged structs
+func TestUnmarshal(t *testing.T) {
+	units := parseTestSave(t)
+
+	t.Run("scalars, placement and pointer IDs", func(t *testing.T) {
+		var player testPlayer
+		if err := UnmarshalResolve(units[2], &player, testResolver(units)); err != nil {
+			t.Fatalf("UnmarshalResolve() error = %v", err)
+		}
+
+		if player.Truck == nil || player.Truck.Odometer != 512345 {
+			t.Errorf("Truck = %+v", player.Truck)
+		}
+
+		if player.HqCity != "berlin" || player.AssignedTruck != "_nameless.1d4.8d36.7060" {
+			t.Errorf("Unmarshal() = %+v", player)
+		}
+
+		wantPos := [3]float64{-35478.76, 29.54, 7826.03}
+		if player.TrailerPlacement.Pos != wantPos || player.TrailerPlacement.Rot[0] != 0.9998 {
+			t.Errorf("TrailerPlacement = %+v", player.TrailerPlacement)
+		}
+	})
+
+	t.Run("slices and hex floats", func(t *testing.T) {
+		var economy struct {
+			Companies []string `sii:"companies"`
+			GameTime  int      `sii:"game_time"`
+		}
+		if err := Unmarshal(units[0], &economy); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if len(economy.Companies) != 2 || economy.Companies[1] != "company.volatile.volvo_dlr.berlin" || economy.GameTime != 24771 {
+			t.Errorf("Unmarshal() = %+v", economy)
+		}
+
+		// An empty array is written as job_offer: 0
+		company := struct {
+			JobOffers []string `sii:"job_offer"`
+		}{JobOffers: []string{"stale"}}
+		if err := Unmarshal(units[5], &company); err != nil || company.JobOffers != nil {
+			t.Errorf("Unmarshal() empty array = %+v, %v", company, err)
+		}
+
+		var bank struct {
+			Coinsurance float32 `sii:"coinsurance_fixed"`
+			Money       *int64  `sii:"money_account"`
+		}
+		if err := Unmarshal(units[1], &bank); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if bank.Coinsurance != 1 || bank.Money == nil || *bank.Money != 1523644 {
+			t.Errorf("Unmarshal() = %+v", bank)
+		}
+	})
+
+	t.Run("nested units through resolver", func(t *testing.T) {
+		var vehicle testVehicle
+		if err := UnmarshalResolve(units[6], &vehicle, testResolver(units)); err != nil {
+			t.Fatalf("UnmarshalResolve() error = %v", err)
+		}
+
+		if vehicle.Utype != "vehicle" || vehicle.Odometer != 512345 || len(vehicle.Accessories) != 2 {
+			t.Fatalf("UnmarshalResolve() = %+v", vehicle)
+		}
+
+		if !strings.Contains(vehicle.Accessories[1].DataPath, "chassis") || vehicle.Accessories[1].ID != "_nameless.1d4.8d36.7090" {
+			t.Errorf("Accessories[1] = %+v", vehicle.Accessories[1])
+		}
+	})
+
+	t.Run("vectors into user types", func(t *testing.T) {
+		unit := Unit{Attrs: newAttributes()}
+		unit.Attrs.addAttribute("pos", "(1.5, 2.5, 3.5)", nil)
+		unit.Attrs.addAttribute("size", "(10, 20)", nil)
+
+		var v struct {
+			Pos  testVec3   `sii:"pos"`
+			Arr  [3]float32 `sii:"pos"`
+			Size [2]uint8   `sii:"size"`
+		}
+		if err := Unmarshal(unit, &v); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+
+		if v.Pos != (testVec3{1.5, 2.5, 3.5}) || v.Arr != [3]float32{1.5, 2.5, 3.5} || v.Size != [2]uint8{10, 20} {
+			t.Errorf("Unmarshal() = %+v", v)
+		}
+	})
+}
+
+// TestUnmarshalErrors tests that mismatches are reported clearly
+func TestUnmarshalErrors(t *testing.T) {
+	units := parseTestSave(t)
+
+	var wrongType struct {
+		Money string `sii:"money_account"`
+	}
+	err := Unmarshal(units[1], &wrongType)
+
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Key != "money_account" || typeErr.Atype != "int" {
+		t.Errorf("Unmarshal() error = %v, want UnmarshalTypeError for money_account", err)
+	}
+	if !errors.Is(err, ErrInvalidType) {
+		t.Errorf("Unmarshal() error = %v, want ErrInvalidType", err)
+	}
+
+	var overflow struct {
+		Money int8 `sii:"money_account"`
+	}
+	if err := Unmarshal(units[1], &overflow); !errors.As(err, &typeErr) {
+		t.Errorf("Unmarshal() error = %v, want overflow UnmarshalTypeError", err)
+	}
+
+	var wrongElem struct {
+		Companies []int `sii:"companies"`
+	}
+	if err := Unmarshal(units[0], &wrongElem); !errors.As(err, &typeErr) || typeErr.Key != "companies[0]" {
+		t.Errorf("Unmarshal() error = %v, want UnmarshalTypeError for companies[0]", err)
+	}
+
+	if err := Unmarshal(units[0], wrongElem); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("Unmarshal() error = %v, want ErrInvalidTarget", err)
+	}
+
+	var player testPlayer
+	if err := Unmarshal(units[2], &player); !errors.As(err, &typeErr) || typeErr.Key != "assigned_truck" {
+		t.Errorf("Unmarshal() error = %v, want UnmarshalTypeError without resolver", err)
+	}
+
+	var unresolved struct {
+		Bank struct {
+			Money int64 `sii:"money_account"`
+		} `sii:"bank"`
+	}
+	if err := UnmarshalResolve(units[0], &unresolved, testResolver(nil)); !errors.Is(err, ErrUnresolved) {
+		t.Errorf("UnmarshalResolve() error = %v, want ErrUnresolved", err)
+	}
+}
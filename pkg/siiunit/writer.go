@@ -0,0 +1,139 @@
+package siiunit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WriteUnits writes units as a SII text document, the same format the parsers read
+func WriteUnits(w io.Writer, units []Unit) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("SiiNunit\n{\n")
+	for _, unit := range units {
+		writeUnit(bw, unit)
+		bw.WriteString("\n")
+	}
+	bw.WriteString("}\n")
+
+	return bw.Flush()
+}
+
+// WriteUnit writes a single unit block
+func WriteUnit(w io.Writer, unit Unit) error {
+	bw := bufio.NewWriter(w)
+	writeUnit(bw, unit)
+	return bw.Flush()
+}
+
+func writeUnit(bw *bufio.Writer, unit Unit) {
+	writeComment(bw, "", unit.Comment)
+	bw.WriteString(unit.Utype)
+	bw.WriteString(" : ")
+	bw.WriteString(unit.ID)
+	bw.WriteString(" {\n")
+
+	for key, attr := range unit.Attrs.All() {
+		writeComment(bw, " ", unit.Attrs.Comment(key))
+
+		if attr.Atype != AttributeTypeArray {
+			fmt.Fprintf(bw, " %s: %s\n", key, attr.siiValue())
+			continue
+		}
+
+		// Arrays are written as their length followed by one line per element
+		fmt.Fprintf(bw, " %s: %d\n", key, len(attr.arrayVals))
+		for i := range attr.arrayVals {
+			fmt.Fprintf(bw, " %s[%d]: %s\n", key, i, attr.arrayVals[i].siiValue())
+		}
+	}
+
+	bw.WriteString("}\n")
+}
+
+// writeComment writes every line of comment as a # comment line
+func writeComment(bw *bufio.Writer, indent, comment string) {
+	if comment == "" {
+		return
+	}
+	for line := range strings.SplitSeq(comment, "\n") {
+		bw.WriteString(indent)
+		bw.WriteString(strings.TrimRight("# "+line, " "))
+		bw.WriteString("\n")
+	}
+}
+
+// siiValue returns the attribute value the way it is written in a SII file.
+// Arrays have no single line value and return their length.
+func (a *Attribute) siiValue() string {
+	switch a.Atype {
+	case AttributeTypeString:
+		if a.quoted {
+			return `"` + a.stringVal + `"`
+		}
+		return a.stringVal
+	case AttributeTypeFloat:
+		return a.formatFloat(a.floatVal, 0)
+	case AttributeTypeFloat2:
+		return a.formatFloats(a.float2Vals[:], 0)
+	case AttributeTypeFloat3:
+		return a.formatFloats(a.float3Vals[:], 0)
+	case AttributeTypeFloat4:
+		return a.formatFloats(a.float4Vals[:], 0)
+	case AttributeTypePlacement:
+		return fmt.Sprintf("%s (%s; %s)",
+			a.formatFloats(a.placementPos[:], 0),
+			a.formatFloat(a.placementRot[0], 3),
+			strings.Trim(a.formatFloats(a.placementRot[1:], 4), "()"))
+	case AttributeTypeInt:
+		return strconv.FormatInt(a.intVal, 10)
+	case AttributeTypeInt2:
+		return formatInts(a.int2Vals[:])
+	case AttributeTypeInt3:
+		return formatInts(a.int3Vals[:])
+	case AttributeTypeInt4:
+		return formatInts(a.int4Vals[:])
+	case AttributeTypeBool:
+		return strconv.FormatBool(a.boolVal)
+	case AttributeTypeArray:
+		return strconv.Itoa(len(a.arrayVals))
+	default:
+		return ""
+	}
+}
+
+// formatFloat writes f, float i of the attribute, in hex if it was read that way.
+// NaN and infinities have no decimal form and are always written in hex. Decimal
+// floats always keep a fraction so they don't read back as ints.
+func (a *Attribute) formatFloat(f float64, i int) string {
+	if a.isHex(i) || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprintf("&%08x", math.Float32bits(float32(f)))
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatFloats writes a tuple of the floats of the attribute starting at float first
+func (a *Attribute) formatFloats(fs []float64, first int) string {
+	parts := make([]string, len(fs))
+	for i, f := range fs {
+		parts[i] = a.formatFloat(f, first+i)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func formatInts(is []int64) string {
+	parts := make([]string, len(is))
+	for i, n := range is {
+		parts[i] = strconv.FormatInt(n, 10)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
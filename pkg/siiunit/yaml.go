@@ -0,0 +1,449 @@
+package siiunit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrYAMLSyntax = errors.New("yaml syntax error")
+
+// The YAML form holds one document per unit, values are written like in SII with
+// tuples as flow sequences:
+//
+//	---
+//	# Comments above units and attributes are kept
+//	utype: vehicle
+//	id: _nameless.1d4.8d36.7060
+//	attrs:
+//	  license_plate: "AB 123 CD"
+//	  engine_wear: 0.25
+//	  odometer_float_part: !hex 0.5
+//	  position: [1.0, 2.0, 3.0]
+//	  placement: [[1.0, 2.0, 3.0], [1.0, 0.0, 0.0, 0.0]]
+//	  size: [1, 2, 3]
+//	  accessories:
+//	    - !token "_nameless.1d4.8d36.7080"
+//	  cargo: []
+//
+// Strings are double quoted and tokens are double quoted with a !token tag, so both
+// read back as they were, even when empty. Backslashes and quotes in them are
+// escaped as \\ and \". Floats always have a fraction so they don't read back as
+// ints, NaN and infinities are .nan, .inf and -.inf without their NaN payload. !hex
+// marks floats written in &xxxxxxxx form, they are shown as their shortest decimal
+// value. Tuples mixing both forms tag their hex elements one by one, [1.5, !hex 0.25].
+// Only this subset of YAML is read back.
+
+// WriteUnitsYAML writes units as YAML documents, see ReadUnitsYAML for the way back
+func WriteUnitsYAML(w io.Writer, units []Unit) error {
+	bw := bufio.NewWriter(w)
+	for _, unit := range units {
+		writeUnitYAML(bw, unit)
+	}
+	return bw.Flush()
+}
+
+func writeUnitYAML(bw *bufio.Writer, unit Unit) {
+	bw.WriteString("---\n")
+	writeComment(bw, "", unit.Comment)
+	fmt.Fprintf(bw, "utype: %s\nid: %s\n", unit.Utype, unit.ID)
+
+	if unit.Attrs.Len() == 0 {
+		bw.WriteString("attrs: {}\n")
+		return
+	}
+
+	bw.WriteString("attrs:\n")
+	for key, attr := range unit.Attrs.All() {
+		writeComment(bw, "  ", unit.Attrs.Comment(key))
+
+		switch {
+		case attr.Atype != AttributeTypeArray:
+			fmt.Fprintf(bw, "  %s: %s\n", key, attr.yamlValue())
+		case len(attr.arrayVals) == 0:
+			fmt.Fprintf(bw, "  %s: []\n", key)
+		default:
+			fmt.Fprintf(bw, "  %s:\n", key)
+			for i := range attr.arrayVals {
+				fmt.Fprintf(bw, "    - %s\n", attr.arrayVals[i].yamlValue())
+			}
+		}
+	}
+}
+
+// yamlValue returns the value of a non array attribute in YAML form
+func (a *Attribute) yamlValue() string {
+	var value string
+	switch a.Atype {
+	case AttributeTypeFloat:
+		value = a.yamlFloat(a.floatVal, 0)
+	case AttributeTypeFloat2:
+		value = a.yamlFloats(a.float2Vals[:], 0)
+	case AttributeTypeFloat3:
+		value = a.yamlFloats(a.float3Vals[:], 0)
+	case AttributeTypeFloat4:
+		value = a.yamlFloats(a.float4Vals[:], 0)
+	case AttributeTypePlacement:
+		value = "[" + a.yamlFloats(a.placementPos[:], 0) + ", " + a.yamlFloats(a.placementRot[:], 3) + "]"
+	case AttributeTypeString:
+		if a.quoted {
+			return yamlQuote(a.stringVal)
+		}
+		return "!token " + yamlQuote(a.stringVal)
+	case AttributeTypeInt2:
+		return yamlInts(a.int2Vals[:])
+	case AttributeTypeInt3:
+		return yamlInts(a.int3Vals[:])
+	case AttributeTypeInt4:
+		return yamlInts(a.int4Vals[:])
+	default:
+		return a.siiValue()
+	}
+
+	if a.hexFloat && a.decimals == 0 {
+		return "!hex " + value
+	}
+	return value
+}
+
+// yamlFloat writes f, float i of the attribute, in decimal. Hex floats are written as
+// the shortest value giving the same bits, tagged one by one if the attribute mixes
+// hex and decimal floats.
+func (a *Attribute) yamlFloat(f float64, i int) string {
+	bitSize := 64
+	if a.isHex(i) {
+		bitSize = 32
+	}
+
+	var s string
+	switch {
+	case math.IsNaN(f):
+		s = ".nan"
+	case math.IsInf(f, 1):
+		s = ".inf"
+	case math.IsInf(f, -1):
+		s = "-.inf"
+	default:
+		s = strconv.FormatFloat(f, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+	}
+	if a.isHex(i) && a.decimals != 0 {
+		s = "!hex " + s
+	}
+	return s
+}
+
+// yamlFloats writes a flow sequence of the floats of the attribute starting at float first
+func (a *Attribute) yamlFloats(fs []float64, first int) string {
+	parts := make([]string, len(fs))
+	for i, f := range fs {
+		parts[i] = a.yamlFloat(f, first+i)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// yamlQuote writes s as a double quoted YAML scalar
+func yamlQuote(s string) string {
+	return `"` + yamlEscaper.Replace(s) + `"`
+}
+
+var yamlEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// yamlUnquote reads a scalar written by yamlQuote, it reports false if value isn't one
+func yamlUnquote(value string) (string, bool) {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", false
+	}
+
+	var sb strings.Builder
+	value = value[1 : len(value)-1]
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			return "", false
+		case '\\':
+			i++
+			if i == len(value) || (value[i] != '\\' && value[i] != '"') {
+				return "", false
+			}
+		}
+		sb.WriteByte(value[i])
+	}
+	return sb.String(), true
+}
+
+func yamlInts(is []int64) string {
+	parts := make([]string, len(is))
+	for i, n := range is {
+		parts[i] = strconv.FormatInt(n, 10)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// ReadUnitsYAML reads units written by WriteUnitsYAML
+func ReadUnitsYAML(r io.Reader) ([]Unit, error) {
+	scanner := bufio.NewScanner(r)
+
+	var units []Unit
+	var unit *Unit
+	var comments []string // Comment lines waiting for the next unit or attribute
+	var arrKey string     // Array taking the following list items
+	inAttrs := false
+
+	flush := func() error {
+		if unit == nil {
+			return nil
+		}
+		if unit.Utype == "" || unit.ID == "" {
+			return fmt.Errorf("%w: unit %d has no utype or id", ErrYAMLSyntax, len(units))
+		}
+		units = append(units, *unit)
+		unit = nil
+		return nil
+	}
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		if trimmed == "" {
+			continue
+		}
+		if text, ok := strings.CutPrefix(trimmed, "#"); ok {
+			comments = append(comments, strings.TrimPrefix(text, " "))
+			continue
+		}
+
+		syntaxErr := func(format string, args ...any) error {
+			return fmt.Errorf("%w: line %d: %s", ErrYAMLSyntax, lineNo, fmt.Sprintf(format, args...))
+		}
+
+		if trimmed == "---" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			unit = &Unit{Attrs: newAttributes()}
+			inAttrs, arrKey = false, ""
+			continue
+		}
+		if unit == nil {
+			return nil, syntaxErr("expected ---")
+		}
+
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok {
+			if arrKey == "" || indent == 0 {
+				return nil, syntaxErr("list item outside of an array")
+			}
+
+			elem, err := parseYAMLValue(item)
+			if err != nil {
+				return nil, syntaxErr("%s: %v", arrKey, err)
+			}
+			if err := unit.Attrs.AppendTo(arrKey, elem); err != nil {
+				return nil, syntaxErr("%s: %v", arrKey, err)
+			}
+			attachComment(unit.Attrs, arrKey, comments)
+			comments = nil
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, syntaxErr("expected key: value")
+		}
+		value = strings.TrimSpace(value)
+		arrKey = ""
+
+		if indent == 0 {
+			switch key {
+			case "utype":
+				unit.Utype = value
+				unit.Comment = strings.Join(comments, "\n")
+				comments = nil
+			case "id":
+				unit.ID = value
+			case "attrs":
+				if value != "" && value != "{}" {
+					return nil, syntaxErr("attrs must be a mapping")
+				}
+				inAttrs = true
+			default:
+				return nil, syntaxErr("unknown field %s", key)
+			}
+			continue
+		}
+
+		if !inAttrs {
+			return nil, syntaxErr("attribute %s outside of attrs", key)
+		}
+
+		var attr Attribute
+		if value == "" {
+			// The items follow on the next lines
+			attr.makeArray(0)
+			arrKey = key
+		} else {
+			var err error
+			if attr, err = parseYAMLValue(value); err != nil {
+				return nil, syntaxErr("%s: %v", key, err)
+			}
+		}
+
+		unit.Attrs.set(key, &attr)
+		attachComment(unit.Attrs, key, comments)
+		comments = nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// parseYAMLValue parses a value written by yamlValue or an empty array
+func parseYAMLValue(value string) (Attribute, error) {
+	value, hex := strings.CutPrefix(value, "!hex ")
+	value, token := strings.CutPrefix(value, "!token ")
+
+	var attr Attribute
+	switch {
+	case token:
+		// The token is quoted like a string, it only loses its quotes
+		s, ok := yamlUnquote(value)
+		if !ok {
+			return attr, fmt.Errorf("%w: !token on %s", ErrParsingFailed, value)
+		}
+		attr = Attribute{Atype: AttributeTypeString, stringVal: s}
+
+	case strings.HasPrefix(value, `"`):
+		s, ok := yamlUnquote(value)
+		if !ok {
+			return attr, fmt.Errorf("%w: %s", ErrParsingFailed, value)
+		}
+		attr = Attribute{Atype: AttributeTypeString, stringVal: s, quoted: true}
+
+	case value == "[]":
+		attr.makeArray(0)
+		return attr, nil
+
+	case strings.HasPrefix(value, "[["):
+		// [[x, y, z], [w, x, y, z]] is written (x, y, z) (w; x, y, z) in SII
+		value = yamlHexElems(yamlSpecialFloats.Replace(value))
+		pos, rot, ok := strings.Cut(strings.TrimSuffix(value[1:], "]"), "],")
+		rot = strings.Trim(rot, " []")
+		w, xyz, _ := strings.Cut(rot, ",")
+		if !ok || !strings.HasSuffix(value, "]]") {
+			return attr, fmt.Errorf("%w: %s", ErrParsingFailed, value)
+		}
+
+		attr, _ = parseAttribute("(" + pos[1:] + ") (" + w + ";" + xyz + ")")
+		if attr.Atype != AttributeTypePlacement {
+			return attr, fmt.Errorf("%w: %s", ErrParsingFailed, value)
+		}
+
+	case strings.HasPrefix(value, "["):
+		if !strings.HasSuffix(value, "]") {
+			return attr, fmt.Errorf("%w: %s", ErrParsingFailed, value)
+		}
+
+		attr, _ = parseAttribute("(" + yamlHexElems(yamlSpecialFloats.Replace(value[1:len(value)-1])) + ")")
+		if attr.Atype == AttributeTypeString {
+			return attr, fmt.Errorf("%w: %s", ErrParsingFailed, value)
+		}
+
+	case value == ".nan" || value == ".inf" || value == "-.inf":
+		attr, _ = parseAttribute(yamlSpecialFloats.Replace(value))
+		attr.hexFloat = hex
+
+	default:
+		var err error
+		if attr, err = parseAttribute(value); err != nil {
+			return attr, err
+		}
+	}
+
+	if hex {
+		if !attr.roundToFloat32() {
+			return attr, fmt.Errorf("%w: !hex on a %s value", ErrInvalidType, attributeTypeNames[attr.Atype])
+		}
+		attr.hexFloat = true
+	}
+	return attr, nil
+}
+
+// yamlSpecialFloats turns the YAML NaN and infinities into the &xxxxxxxx form
+var yamlSpecialFloats = strings.NewReplacer(".nan", "&7fc00000", "-.inf", "&ff800000", ".inf", "&7f800000")
+
+// yamlHexElems turns the !hex tagged elements of a sequence mixing hex and decimal
+// floats into the &xxxxxxxx form. Malformed elements are kept and fail to parse.
+func yamlHexElems(value string) string {
+	var sb strings.Builder
+	for {
+		before, after, ok := strings.Cut(value, "!hex ")
+		sb.WriteString(before)
+		if !ok {
+			return sb.String()
+		}
+
+		if strings.HasPrefix(after, "&") {
+			// NaN and infinities are in hex already
+			value = after
+			continue
+		}
+
+		end, _ := scanNumber(after, 0)
+		f, err := strconv.ParseFloat(after[:end], 32)
+		if err != nil {
+			sb.WriteString("!hex ")
+			value = after
+			continue
+		}
+		fmt.Fprintf(&sb, "&%08x", math.Float32bits(float32(f)))
+		value = after[end:]
+	}
+}
+
+// roundToFloat32 rounds the floats of the attribute to the precision of hex floats,
+// it reports false if the attribute holds no floats
+func (a *Attribute) roundToFloat32() bool {
+	var fs []float64
+	switch a.Atype {
+	case AttributeTypeFloat:
+		fs = []float64{a.floatVal}
+	case AttributeTypeFloat2:
+		fs = a.float2Vals[:]
+	case AttributeTypeFloat3:
+		fs = a.float3Vals[:]
+	case AttributeTypeFloat4:
+		fs = a.float4Vals[:]
+	case AttributeTypePlacement:
+		fs = append(a.placementPos[:], a.placementRot[:]...)
+	default:
+		return false
+	}
+
+	for i, f := range fs {
+		fs[i] = float64(float32(f))
+	}
+
+	switch a.Atype {
+	case AttributeTypeFloat:
+		a.floatVal = fs[0]
+	case AttributeTypePlacement:
+		copy(a.placementPos[:], fs[:3])
+		copy(a.placementRot[:], fs[3:])
+	}
+	return true
+}
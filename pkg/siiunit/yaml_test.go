@@ -0,0 +1,163 @@
+package siiunit
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const commentedSii = `SiiNunit
+{
+# The player's truck
+// set up by hand
+vehicle : _nameless.1d4.8d36.7060 {
+ # Scania plate
+ license_plate: "AB 123 CD"
+ engine_wear: &3e800000
+ odometer: 512345
+ position: (1.5, 2.0, -3.25)
+ cabin: (&3f800000, &3f000000)
+ placement: (1.0, 2.0, 3.0) (1.0; 0.0, 0.0, 0.0)
+ size: (1, 2, 3)
+ broken: false
+ # Everything bolted on
+ accessories: 2
+ accessories[0]: _nameless.1d4.8d36.7080
+ accessories[1]: _nameless.1d4.8d36.7090
+ cargo: 0
+}
+
+vehicle_accessory : _nameless.1d4.8d36.7080 {
+ data_path: "/def/vehicle/truck/scania.r/engine/dc13.sii"
+}
+
+}
+`
+
+const commentedYAML = `---
+# The player's truck
+# set up by hand
+utype: vehicle
+id: _nameless.1d4.8d36.7060
+attrs:
+  # Scania plate
+  license_plate: "AB 123 CD"
+  engine_wear: !hex 0.25
+  odometer: 512345
+  position: [1.5, 2.0, -3.25]
+  cabin: !hex [1.0, 0.5]
+  placement: [[1.0, 2.0, 3.0], [1.0, 0.0, 0.0, 0.0]]
+  size: [1, 2, 3]
+  broken: false
+  # Everything bolted on
+  accessories:
+    - !token "_nameless.1d4.8d36.7080"
+    - !token "_nameless.1d4.8d36.7090"
+  cargo: 0
+---
+utype: vehicle_accessory
+id: _nameless.1d4.8d36.7080
+attrs:
+  data_path: "/def/vehicle/truck/scania.r/engine/dc13.sii"
+`
+
+// TestUnitsYAML tests the YAML form of commented units and that it converts back to the same SII
+func TestUnitsYAML(t *testing.T) {
+	for _, parse := range []func(string) ([]Unit, error){
+		func(s string) ([]Unit, error) { return ParseAllUnits(strings.NewReader(s)) },
+		func(s string) ([]Unit, error) { return ParseAllUnitsConcurrent(strings.NewReader(s)) },
+	} {
+		units, err := parse(commentedSii)
+		if err != nil {
+			t.Fatalf("parse error = %v", err)
+		}
+
+		var sb strings.Builder
+		if err := WriteUnitsYAML(&sb, units); err != nil {
+			t.Fatalf("WriteUnitsYAML() error = %v", err)
+		}
+		if sb.String() != commentedYAML {
+			t.Errorf("WriteUnitsYAML() =\n%s\nwant\n%s", sb.String(), commentedYAML)
+		}
+
+		decoded, err := ReadUnitsYAML(strings.NewReader(sb.String()))
+		if err != nil {
+			t.Fatalf("ReadUnitsYAML() error = %v", err)
+		}
+
+		// Comments are written back with #
+		want := strings.ReplaceAll(commentedSii, "// set", "# set")
+		if got := writeTestUnits(t, decoded); got != want {
+			t.Errorf("WriteUnits() =\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+// TestUnitsYAMLRoundTrip tests a whole save survives YAML unchanged
+func TestUnitsYAMLRoundTrip(t *testing.T) {
+	units := parseTestSave(t)
+	units[1].Attrs.Set("shares", mustArray(t, NewHexFloatAttribute(0.1), NewHexFloatAttribute(1)))
+	units[1].Attrs.Set("spot", NewPlacementAttribute([3]float64{-35478.76, 29.54, 7826.03}, [4]float64{0.9998, 0, -0.0179, 0}))
+	units[1].Attrs.Set("empty", mustArray(t))
+	units[1].Attrs.Set("null_ptr", NewNullAttribute())
+	units[1].Attrs.Set("name", NewStringAttribute("Truck (old) [2]"))
+	units[1].Attrs.Set("names", mustArray(t, NewStringAttribute("(a)"), NewStringAttribute("")))
+	units[1].Attrs.Set("no_name", NewStringAttribute(""))
+	units[1].Attrs.Set("no_token", NewTokenAttribute(""))
+	units[1].Attrs.Set("path", NewStringAttribute(`C:\saves \"1\"`))
+	units[1].Attrs.Set("size", NewInt3Attribute([3]int64{1, -2, 3}))
+
+	var sb strings.Builder
+	if err := WriteUnitsYAML(&sb, units); err != nil {
+		t.Fatalf("WriteUnitsYAML() error = %v", err)
+	}
+
+	for _, line := range []string{`  name: "Truck (old) [2]"`, `    - ""`, `  no_name: ""`, `  no_token: !token ""`, `  path: "C:\\saves \\\"1\\\""`} {
+		if !strings.Contains(sb.String(), line+"\n") {
+			t.Errorf("WriteUnitsYAML() has no line %s:\n%s", line, sb.String())
+		}
+	}
+
+	decoded, err := ReadUnitsYAML(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("ReadUnitsYAML() error = %v", err)
+	}
+
+	if got, want := writeTestUnits(t, decoded), writeTestUnits(t, units); got != want {
+		t.Errorf("YAML round trip =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestReadUnitsYAMLErrors tests malformed YAML is rejected with the line it failed on
+func TestReadUnitsYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		line string
+	}{
+		{name: "no document start", yaml: "utype: bank\n", line: "line 1"},
+		{name: "unknown field", yaml: "---\nutype: bank\nid: x\nname: y\n", line: "line 4"},
+		{name: "attribute outside attrs", yaml: "---\nutype: bank\n  money: 1\n", line: "line 3"},
+		{name: "stray list item", yaml: "---\nutype: bank\nid: x\nattrs:\n  money: 1\n    - 2\n", line: "line 6"},
+		{name: "bad tuple", yaml: "---\nutype: bank\nid: x\nattrs:\n  pos: [1, x]\n", line: "line 5"},
+		{name: "bad placement", yaml: "---\nutype: bank\nid: x\nattrs:\n  pos: [[1.0, 2.0], [1.0]]\n", line: "line 5"},
+		{name: "unquoted token", yaml: "---\nutype: bank\nid: x\nattrs:\n  a: !token x\n", line: "line 5"},
+		{name: "unescaped quote", yaml: "---\nutype: bank\nid: x\nattrs:\n  a: \"b\"c\"\n", line: "line 5"},
+		{name: "unknown escape", yaml: "---\nutype: bank\nid: x\nattrs:\n  a: \"b\\nc\"\n", line: "line 5"},
+		{name: "hex int", yaml: "---\nutype: bank\nid: x\nattrs:\n  money: !hex 1\n", line: "line 5"},
+		{name: "mixed array", yaml: "---\nutype: bank\nid: x\nattrs:\n  a:\n    - 1\n    - x\n", line: "line 7"},
+		{name: "missing id", yaml: "---\nutype: bank\nattrs: {}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadUnitsYAML(strings.NewReader(tt.yaml))
+			if !errors.Is(err, ErrYAMLSyntax) {
+				t.Fatalf("ReadUnitsYAML() error = %v, want %v", err, ErrYAMLSyntax)
+			}
+			if !strings.Contains(err.Error(), tt.line) {
+				t.Errorf("ReadUnitsYAML() error = %v, want it at %s", err, tt.line)
+			}
+		})
+	}
+}